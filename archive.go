@@ -0,0 +1,193 @@
+package digdaggo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProjectArchive holds the files of a project archive in memory, by slash-separated path
+// relative to the project root.
+type ProjectArchive struct {
+	Files map[string][]byte
+}
+
+// ReadProjectArchive reads a gzipped tar project archive into memory.
+func ReadProjectArchive(r io.Reader) (*ProjectArchive, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	archive := &ProjectArchive{Files: map[string][]byte{}}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return archive, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." || name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, fmt.Errorf("invalid file name in archive: %q", header.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		archive.Files[name] = content
+	}
+}
+
+// Names returns the file names in order.
+func (a *ProjectArchive) Names() []string {
+	names := make([]string, 0, len(a.Files))
+	for name := range a.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FS returns a read-only file system over the files of the archive.
+func (a *ProjectArchive) FS() fs.FS {
+	return archiveFS(a.Files)
+}
+
+// archiveFS serves files from memory. Directories are derived from the file names.
+type archiveFS map[string][]byte
+
+func (fsys archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if content, ok := fsys[name]; ok {
+		info := &archiveFileInfo{name: path.Base(name), size: int64(len(content))}
+		return &archiveFile{info: info, Reader: bytes.NewReader(content)}, nil
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := map[string]*archiveFileInfo{}
+	for file, content := range fsys {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		child, rest, isDir := strings.Cut(file[len(prefix):], "/")
+		if isDir && rest != "" {
+			children[child] = &archiveFileInfo{name: child, dir: true}
+		} else if children[child] == nil {
+			children[child] = &archiveFileInfo{name: child, size: int64(len(content))}
+		}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, child)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &archiveDir{info: &archiveFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+type archiveFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi *archiveFileInfo) Name() string               { return fi.name }
+func (fi *archiveFileInfo) Size() int64                { return fi.size }
+func (fi *archiveFileInfo) ModTime() time.Time         { return time.Time{} }
+func (fi *archiveFileInfo) IsDir() bool                { return fi.dir }
+func (fi *archiveFileInfo) Sys() interface{}           { return nil }
+func (fi *archiveFileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *archiveFileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+func (fi *archiveFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type archiveFile struct {
+	info *archiveFileInfo
+	*bytes.Reader
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *archiveFile) Close() error               { return nil }
+
+type archiveDir struct {
+	info    *archiveFileInfo
+	entries []fs.DirEntry
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *archiveDir) Close() error               { return nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the remaining entries like os.File.ReadDir.
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 || n > len(d.entries) {
+		if n > 0 && len(d.entries) == 0 {
+			return nil, io.EOF
+		}
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// GetProjectArchive downloads the archive of a project revision into memory.
+// The latest revision is downloaded when revision is empty.
+func (c *Client) GetProjectArchive(ctx context.Context, projectId, revision string) (*ProjectArchive, error) {
+	parameters := map[string]string{"direct_download": "false"}
+	if revision != "" {
+		parameters["revision"] = revision
+	}
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("projects/%s/archive", projectId), parameters, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ReadProjectArchive(bytes.NewReader(body))
+}
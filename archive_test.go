@@ -0,0 +1,68 @@
+package digdaggo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// buildArchive builds a gzipped tar project archive of the given files.
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadProjectArchive(t *testing.T) {
+	data := buildArchive(t, map[string]string{
+		"./daily.dig":        "+a:\n  echo>: a\n",
+		"queries/report.sql": "select 1",
+	})
+	archive, err := ReadProjectArchive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := archive.Names()
+	if len(names) != 2 || names[0] != "daily.dig" || names[1] != "queries/report.sql" {
+		t.Fatalf("names wrong. got=%v", names)
+	}
+	content, err := fs.ReadFile(archive.FS(), "queries/report.sql")
+	if err != nil || string(content) != "select 1" {
+		t.Fatalf("file system wrong. got=%q, %v", content, err)
+	}
+	if err := fstest.TestFS(archive.FS(), "daily.dig", "queries/report.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadProjectArchive(bytes.NewReader(buildArchive(t, map[string]string{"../evil": "x"}))); err == nil {
+		t.Fatal("expected an error for a file outside of the project")
+	}
+	if _, err := ReadProjectArchive(bytes.NewReader(buildArchive(t, map[string]string{"..": "x"}))); err == nil {
+		t.Fatal("expected an error for a file named ..")
+	}
+}
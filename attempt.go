@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"io"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -54,21 +57,20 @@ func (c *Client) GetAttempts(ctx context.Context, projectName, workflowName, las
 	}
 
 	if lastId != "" {
-		param["lastId"] = lastId
+		param["last_id"] = lastId
 	}
 
 	if pageSize != "" {
-		param[pageSize] = pageSize
+		param["page_size"] = pageSize
 	}
 	if includeRetried {
-		param["includeRetried"] = "true"
+		param["include_retried"] = "true"
 	}
 	req, err := c.newRequest(ctx, "GET", "attempts", param, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	resp, err := c.HTTPClient.Do(req)
-	fmt.Println(resp)
 	if err != nil {
 		return nil, err
 	}
@@ -84,24 +86,29 @@ func (c *Client) GetAttempts(ctx context.Context, projectName, workflowName, las
 	return &attemptList, nil
 }
 
-type Mode int
-
-const (
-	FAILED Mode = iota
-	FROM
-)
-
-type resume struct {
-	AttemptId interface{} `json:"attemptId"`
-	Mode      Mode        `json:"mode"`
-}
-
-type RetryAttemptBody struct {
-	SessionTime      time.Time   `json:"sessionTime"`
-	WorkflowId       int64       `json:"workflowId"`
-	Resume           resume      `json:"resume"`
-	RetryAttemptName string      `json:"retryAttemptName"`
-	Params           interface{} `json:"params"`
+// forEachAttempt pages through GetAttempts, newest attempt first, until fn returns false.
+func (c *Client) forEachAttempt(ctx context.Context, projectName, workflowName string, includeRetried bool, fn func(*Attempt) (bool, error)) error {
+	lastId := ""
+	for {
+		page, err := c.GetAttempts(ctx, projectName, workflowName, lastId, "100", includeRetried)
+		if err != nil {
+			return err
+		}
+		if len(page.Attempts) == 0 {
+			return nil
+		}
+		for i := range page.Attempts {
+			more, err := fn(&page.Attempts[i])
+			if err != nil || !more {
+				return err
+			}
+		}
+		next := page.Attempts[len(page.Attempts)-1].ID
+		if next == lastId {
+			return nil
+		}
+		lastId = next
+	}
 }
 
 type AttemptBody struct {
@@ -110,17 +117,54 @@ type AttemptBody struct {
 	Params      interface{} `json:"params"`
 }
 
-func (c *Client) StartAttempt(ctx context.Context, params interface{}, workflowID int64, sessionTime time.Time) (*Attempt, error) {
+// AttemptOption configures how an attempt is started.
+type AttemptOption func(*attemptOptions)
+
+type attemptOptions struct {
+	sessionTimeMode SessionTimeMode
+	validate        bool
+}
+
+func newAttemptOptions(opts []AttemptOption) *attemptOptions {
+	o := &attemptOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSessionTimeMode truncates the session time on the server like StartWorkflowOptions.SessionTimeMode,
+// e.g. to the last schedule time of the workflow for a manual run of a scheduled session.
+func WithSessionTimeMode(mode SessionTimeMode) AttemptOption {
+	return func(o *attemptOptions) {
+		o.sessionTimeMode = mode
+	}
+}
+
+func (c *Client) StartAttempt(ctx context.Context, params interface{}, workflowID int64, sessionTime time.Time, opts ...AttemptOption) (*Attempt, error) {
+	if mode := newAttemptOptions(opts).sessionTimeMode; mode != "" {
+		if sessionTime.IsZero() {
+			sessionTime = time.Now()
+		}
+		truncated, err := c.GetTruncatedSessionTime(ctx, strconv.FormatInt(workflowID, 10), sessionTime, mode)
+		if err != nil {
+			return nil, err
+		}
+		sessionTime = truncated.SessionTime
+	}
 	startAttemptBody := AttemptBody{
 		SessionTime: sessionTime,
 		WorkflowId:  workflowID,
 		Params:      params,
 	}
-	jsn, err := json.Marshal(startAttemptBody)
+	return c.putAttempt(ctx, startAttemptBody)
+}
+
+func (c *Client) putAttempt(ctx context.Context, body interface{}) (*Attempt, error) {
+	jsn, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("%s\n", jsn)
 	header := map[string]string{"content-type": "application/json"}
 	req, err := c.newRequest(ctx, "PUT", "attempts", nil, bytes.NewBuffer(jsn), header)
 	if err != nil {
@@ -148,55 +192,195 @@ func (c *Client) StartAttempt(ctx context.Context, params interface{}, workflowI
 	return &attempt, nil
 }
 
-func (c *Client) RetryAttempt(ctx context.Context, mode Mode, params interface{}, workflowId int64, attemptId interface{}, sessionTime time.Time) (*Attempt, error) {
-	attemptNameUUID, err := uuid.NewUUID()
+// StartWorkflowOptions describes an attempt to start by project and workflow name.
+type StartWorkflowOptions struct {
+	Project  string
+	Workflow string
+	// Revision pins the workflow to a project revision. The latest revision is used when empty.
+	Revision string
+	// SessionTime is the session time of the attempt. The current time is used when zero.
+	SessionTime time.Time
+	// SessionTimeMode truncates SessionTime on the server. The session time is only
+	// normalized to the workflow's time zone when empty.
+	SessionTimeMode SessionTimeMode
+	Params          interface{}
+}
+
+// StartWorkflow resolves the workflow ID from the project and workflow name and starts an attempt of it.
+func (c *Client) StartWorkflow(ctx context.Context, opts StartWorkflowOptions) (*Attempt, error) {
+	if opts.Project == "" || opts.Workflow == "" {
+		return nil, errors.New("project and workflow name must be specified")
+	}
+	wf, err := c.ResolveWorkflowAtRevision(ctx, opts.Project+"/"+opts.Workflow, opts.Revision)
 	if err != nil {
 		return nil, err
 	}
-	if attemptId != nil {
-		attemptId = attemptId.(int64)
+	workflowID, err := wf.NumericID()
+	if err != nil {
+		return nil, err
 	}
-	if attemptId == nil {
-		attemptId = ""
+	sessionTime := opts.SessionTime
+	if sessionTime.IsZero() || opts.SessionTimeMode != "" {
+		if sessionTime.IsZero() {
+			sessionTime = time.Now()
+		}
+		truncated, err := c.GetTruncatedSessionTime(ctx, wf.ID, sessionTime, opts.SessionTimeMode)
+		if err != nil {
+			return nil, err
+		}
+		sessionTime = truncated.SessionTime
 	}
-	attemptNameUUIDString := attemptNameUUID.String()
-	resumeBody := RetryAttemptBody{
-		SessionTime:      sessionTime,
-		WorkflowId:       workflowId,
-		Params:           params,
-		Resume:           resume{AttemptId: attemptId, Mode: mode},
-		RetryAttemptName: attemptNameUUIDString,
+	params := opts.Params
+	if params == nil {
+		params = map[string]interface{}{}
 	}
-	jsn, err := json.Marshal(resumeBody)
+	return c.StartAttempt(ctx, params, workflowID, sessionTime)
+}
+
+// RetryMode selects which tasks a retried attempt runs.
+type RetryMode string
+
+const (
+	// RetryAll runs every task of the workflow again.
+	RetryAll RetryMode = "all"
+	// RetryFailed resumes the attempt from the tasks which failed.
+	RetryFailed RetryMode = "failed"
+	// RetryFrom resumes the attempt from a named task.
+	RetryFrom RetryMode = "from"
+)
+
+// RetryOptions describes how RetryAttempt retries an attempt.
+type RetryOptions struct {
+	// Mode defaults to RetryAll.
+	Mode RetryMode
+	// From is the name of the task to resume from with RetryFrom, e.g. "+main+load".
+	// A unique suffix of the full task name is accepted too.
+	From string
+	// Params override the params of the original attempt.
+	Params map[string]interface{}
+	// Revision runs the retry on the given project revision instead of the original one.
+	Revision string
+	// UseLatestRevision runs the retry on the latest project revision. It takes precedence over Revision.
+	UseLatestRevision bool
+	// Name is the retry attempt name. A random name is generated when empty.
+	Name string
+}
+
+// AttemptResume tells the server which tasks of a previous attempt a retry resumes from.
+type AttemptResume struct {
+	Mode      RetryMode `json:"mode"`
+	AttemptId string    `json:"attemptId"`
+	From      string    `json:"from,omitempty"`
+}
+
+type RetryAttemptBody struct {
+	SessionTime      time.Time      `json:"sessionTime"`
+	WorkflowId       int64          `json:"workflowId"`
+	Resume           *AttemptResume `json:"resume,omitempty"`
+	RetryAttemptName string         `json:"retryAttemptName"`
+	Params           interface{}    `json:"params"`
+}
+
+// RetryAttempt starts a new attempt in the session of the given attempt and returns it.
+func (c *Client) RetryAttempt(ctx context.Context, attemptId string, opts RetryOptions) (*Attempt, error) {
+	body, _, err := c.newRetryAttemptBody(ctx, attemptId, opts)
 	if err != nil {
 		return nil, err
 	}
-	header := map[string]string{"content-type": "application/json"}
-	fmt.Printf("%s\n", jsn)
-	req, err := c.newRequest(ctx, "PUT", "attempts", nil, bytes.NewBuffer(jsn), header)
+	return c.putAttempt(ctx, body)
+}
+
+// newRetryAttemptBody builds the request of a retry. The workflow is returned only when it was looked up
+// for a revision other than the original one.
+func (c *Client) newRetryAttemptBody(ctx context.Context, attemptId string, opts RetryOptions) (*RetryAttemptBody, *DetailedWorkflow, error) {
+	attempt, err := c.GetAttempt(ctx, attemptId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
+
+	var resume *AttemptResume
+	switch opts.Mode {
+	case "", RetryAll:
+	case RetryFailed:
+		resume = &AttemptResume{Mode: RetryFailed, AttemptId: attempt.ID}
+	case RetryFrom:
+		from, err := c.resolveRetryTask(ctx, attempt.ID, opts.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		resume = &AttemptResume{Mode: RetryFrom, AttemptId: attempt.ID, From: from}
+	default:
+		return nil, nil, fmt.Errorf("unknown retry mode %q", string(opts.Mode))
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+
+	var wf *DetailedWorkflow
+	workflowId := attempt.Workflow.ID
+	if opts.UseLatestRevision || opts.Revision != "" {
+		revision := opts.Revision
+		if opts.UseLatestRevision {
+			revision = ""
+		}
+		wf, err = c.GetProjectWorkflow(ctx, attempt.Project.ID, attempt.Workflow.Name, revision)
 		if err != nil {
-			return
+			return nil, nil, err
 		}
-	}(resp.Body)
-	checkStatus := c.checkHttpResponseCode(resp)
-	if checkStatus != nil {
-		return nil, checkStatus
+		workflowId = wf.ID
 	}
-	var attempt Attempt
-	err = c.decodeBody(resp, &attempt)
+	numericWorkflowId, err := strconv.ParseInt(workflowId, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = uuid.New().String()
+	}
+
+	params := map[string]interface{}{}
+	if original, ok := attempt.Params.(map[string]interface{}); ok {
+		for k, v := range original {
+			params[k] = v
+		}
+	}
+	for k, v := range opts.Params {
+		params[k] = v
+	}
+
+	return &RetryAttemptBody{
+		SessionTime:      attempt.SessionTime,
+		WorkflowId:       numericWorkflowId,
+		Resume:           resume,
+		RetryAttemptName: name,
+		Params:           params,
+	}, wf, nil
+}
+
+// resolveRetryTask checks that the task exists in the attempt and returns its full name.
+func (c *Client) resolveRetryTask(ctx context.Context, attemptId, from string) (string, error) {
+	if from == "" {
+		return "", errors.New("task name to resume from must be specified")
+	}
+	tasks, err := c.ListTasks(ctx, attemptId)
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	for _, task := range tasks.Tasks {
+		if task.FullName == from {
+			return task.FullName, nil
+		}
+		if strings.HasSuffix(task.FullName, from) && strings.HasPrefix(from, "+") {
+			candidates = append(candidates, task.FullName)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("task %q: %w in attempt %s", from, ErrNotFound, attemptId)
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", fmt.Errorf("task %q is ambiguous in attempt %s: %s", from, attemptId, strings.Join(candidates, ", "))
 	}
-	return &attempt, nil
 }
 
 func (c *Client) GetAttempt(ctx context.Context, attemptId string) (*Attempt, error) {
@@ -236,6 +420,96 @@ func (c *Client) KillAttempt(ctx context.Context, attemptId string) error {
 	return nil
 }
 
+// KillWaitOptions controls how KillAttemptAndWait waits for the attempt to finish.
+type KillWaitOptions struct {
+	// Timeout defaults to 10 minutes.
+	Timeout time.Duration
+	// PollInterval defaults to 5 seconds.
+	PollInterval time.Duration
+}
+
+// KillTimeoutError is returned by KillAttemptAndWait when the attempt is still running after the timeout.
+type KillTimeoutError struct {
+	AttemptID string
+	Timeout   time.Duration
+	// RunningTasks are the tasks which had not finished when the timeout expired.
+	RunningTasks []Task
+}
+
+func (e *KillTimeoutError) Error() string {
+	names := make([]string, 0, len(e.RunningTasks))
+	for _, task := range e.RunningTasks {
+		names = append(names, task.FullName)
+	}
+	return fmt.Sprintf("attempt %s was not canceled within %s; still running: %s", e.AttemptID, e.Timeout, strings.Join(names, ", "))
+}
+
+func (e *KillTimeoutError) Unwrap() error {
+	return ErrKillTimeout
+}
+
+// KillAttemptAndWait kills the attempt and waits until it is done, cleanup tasks included.
+// The returned attempt has CancelRequested set unless it finished on its own before the kill took effect.
+// A *KillTimeoutError listing the tasks which are still running is returned when the timeout expires.
+func (c *Client) KillAttemptAndWait(ctx context.Context, attemptId string, opts KillWaitOptions) (*Attempt, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	attempt, err := c.GetAttempt(ctx, attemptId)
+	if err != nil {
+		return nil, err
+	}
+	if attempt.Done {
+		return attempt, nil
+	}
+	if err := c.KillAttempt(ctx, attemptId); err != nil {
+		return nil, err
+	}
+
+	deadline := time.NewTimer(opts.Timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		attempt, err = c.GetAttempt(ctx, attemptId)
+		if err != nil {
+			return nil, err
+		}
+		if attempt.Done {
+			return attempt, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			tasks, err := c.ListTasks(ctx, attemptId)
+			if err != nil {
+				return nil, err
+			}
+			return attempt, &KillTimeoutError{AttemptID: attemptId, Timeout: opts.Timeout, RunningTasks: activeTasks(tasks.Tasks)}
+		case <-ticker.C:
+		}
+	}
+}
+
+// activeTasks returns the tasks which are neither finished nor waiting for other tasks.
+func activeTasks(tasks []Task) []Task {
+	var active []Task
+	for _, task := range tasks {
+		if task.IsGroup {
+			continue
+		}
+		switch task.State {
+		case "ready", "running", "retry_waiting":
+			active = append(active, task)
+		}
+	}
+	return active
+}
+
 func (c *Client) ListAttempts(ctx context.Context, attemptId string) (*AttemptList, error) {
 	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("attempts/%s/retries", attemptId), nil, nil, nil)
 	if err != nil {
@@ -257,29 +531,33 @@ func (c *Client) ListAttempts(ctx context.Context, attemptId string) (*AttemptLi
 	return &attemptList, nil
 }
 
+// TaskError is the error of a failed task.
+type TaskError struct {
+	Message    string `json:"message"`
+	Stacktrace string `json:"stacktrace"`
+}
+
+// Task is a task of an attempt.
+type Task struct {
+	ID              string                 `json:"id"`
+	FullName        string                 `json:"fullName"`
+	ParentID        string                 `json:"parentId"`
+	Config          map[string]interface{} `json:"config"`
+	Upstreams       []string               `json:"upstreams"`
+	State           string                 `json:"state"`
+	CancelRequested bool                   `json:"cancelRequested"`
+	ExportParams    map[string]interface{} `json:"exportParams"`
+	StoreParams     map[string]interface{} `json:"storeParams"`
+	StateParams     map[string]interface{} `json:"stateParams"`
+	UpdatedAt       time.Time              `json:"updatedAt"`
+	RetryAt         time.Time              `json:"retryAt"`
+	StartedAt       time.Time              `json:"startedAt"`
+	Error           TaskError              `json:"error"`
+	IsGroup         bool                   `json:"isGroup"`
+}
+
 type TasksList struct {
-	Tasks []struct {
-		ID       string      `json:"id"`
-		FullName string      `json:"fullName"`
-		ParentID interface{} `json:"parentId"`
-		Config   struct {
-		} `json:"config"`
-		Upstreams       []interface{} `json:"upstreams"`
-		State           string        `json:"state"`
-		CancelRequested bool          `json:"cancelRequested"`
-		ExportParams    struct {
-		} `json:"exportParams"`
-		StoreParams struct {
-		} `json:"storeParams"`
-		StateParams struct {
-		} `json:"stateParams"`
-		UpdatedAt time.Time   `json:"updatedAt"`
-		RetryAt   interface{} `json:"retryAt"`
-		StartedAt time.Time   `json:"startedAt"`
-		Error     struct {
-		} `json:"error"`
-		IsGroup bool `json:"isGroup"`
-	} `json:"tasks"`
+	Tasks []Task `json:"tasks"`
 }
 
 func (c *Client) ListTasks(ctx context.Context, attemptId string) (*TasksList, error) {
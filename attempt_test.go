@@ -2,7 +2,10 @@ package digdaggo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -83,3 +86,243 @@ func TestClient_GetAttempts(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_StartWorkflow(t *testing.T) {
+	truncated, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00+09:00")
+	var body AttemptBody
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects":                   respondJSON(t, Projects{Projects: []Project{{ID: "1", Name: "test"}}}),
+		"GET /projects/1/workflows/daily": respondJSON(t, DetailedWorkflow{ID: "100", Name: "daily"}),
+		"GET /workflows/100/truncated_session_time": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("session_time") == "" {
+				t.Error("session_time must be sent")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, WorkflowSessionTime{SessionTime: truncated, TimeZone: "Asia/Tokyo"})(w, req)
+		},
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, Attempt{ID: "555", Status: "running"})(w, req)
+		},
+	})
+	defer teardown()
+
+	attempt, err := client.StartWorkflow(context.Background(), StartWorkflowOptions{
+		Project:  "test",
+		Workflow: "daily",
+		Params:   map[string]string{"target": "users"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.ID != "555" {
+		t.Fatalf("attempt wrong. got=%+v", attempt)
+	}
+	if body.WorkflowId != 100 || !body.SessionTime.Equal(truncated) {
+		t.Fatalf("request body wrong. got=%+v", body)
+	}
+	if params, ok := body.Params.(map[string]interface{}); !ok || params["target"] != "users" {
+		t.Fatalf("params wrong. got=%+v", body.Params)
+	}
+}
+
+func TestClient_StartAttempt_SessionTimeMode(t *testing.T) {
+	instant, _ := time.Parse(time.RFC3339, "2022-04-01T10:42:00Z")
+	truncated, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00+09:00")
+	var body AttemptBody
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /workflows/100/truncated_session_time": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("mode") != "schedule" || req.URL.Query().Get("session_time") != "2022-04-01T10:42:00Z" {
+				t.Errorf("query wrong. got=%s", req.URL.RawQuery)
+			}
+			respondJSON(t, WorkflowSessionTime{SessionTime: truncated})(w, req)
+		},
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode attempt: %s", err)
+			}
+			respondJSON(t, Attempt{ID: "555"})(w, req)
+		},
+	})
+	defer teardown()
+
+	if _, err := client.StartAttempt(context.Background(), nil, 100, instant, WithSessionTimeMode(SessionTimeSchedule)); err != nil {
+		t.Fatal(err)
+	}
+	if !body.SessionTime.Equal(truncated) {
+		t.Fatalf("session time wrong. got=%s", body.SessionTime)
+	}
+	if _, err := client.StartAttempt(context.Background(), nil, 100, instant, WithSessionTimeMode("week")); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestClient_RetryAttempt(t *testing.T) {
+	sessionTime, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00Z")
+	original := Attempt{
+		ID:          "555",
+		Project:     ProjectInAttempt{ID: "1", Name: "test"},
+		Workflow:    WorkflowInAttempt{ID: "100", Name: "daily"},
+		SessionTime: sessionTime,
+		Params:      map[string]interface{}{"target": "users", "limit": 10},
+	}
+	tasks := `{"tasks": [
+		{"id": "1", "fullName": "+daily", "state": "group_error"},
+		{"id": "2", "fullName": "+daily+load", "state": "success"},
+		{"id": "3", "fullName": "+daily+report+load", "state": "error"},
+		{"id": "4", "fullName": "+daily+report", "state": "group_error"}
+	]}`
+
+	tt := []struct {
+		name         string
+		opts         RetryOptions
+		expectedBody RetryAttemptBody
+		expectedErr  bool
+	}{
+		{
+			name:         "all",
+			opts:         RetryOptions{Name: "retry1"},
+			expectedBody: RetryAttemptBody{WorkflowId: 100, RetryAttemptName: "retry1"},
+		},
+		{
+			name:         "failed with latest revision",
+			opts:         RetryOptions{Mode: RetryFailed, UseLatestRevision: true, Params: map[string]interface{}{"limit": 20}},
+			expectedBody: RetryAttemptBody{WorkflowId: 200, Resume: &AttemptResume{Mode: RetryFailed, AttemptId: "555"}},
+		},
+		{
+			name:         "from full task name",
+			opts:         RetryOptions{Mode: RetryFrom, From: "+daily+report"},
+			expectedBody: RetryAttemptBody{WorkflowId: 100, Resume: &AttemptResume{Mode: RetryFrom, AttemptId: "555", From: "+daily+report"}},
+		},
+		{
+			name:         "from task name suffix",
+			opts:         RetryOptions{Mode: RetryFrom, From: "+report"},
+			expectedBody: RetryAttemptBody{WorkflowId: 100, Resume: &AttemptResume{Mode: RetryFrom, AttemptId: "555", From: "+daily+report"}},
+		},
+		{
+			name:        "from ambiguous task name",
+			opts:        RetryOptions{Mode: RetryFrom, From: "+load"},
+			expectedErr: true,
+		},
+		{
+			name:        "from unknown task",
+			opts:        RetryOptions{Mode: RetryFrom, From: "+missing"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var body RetryAttemptBody
+			client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+				"GET /attempts/555":               respondJSON(t, original),
+				"GET /projects/1/workflows/daily": respondJSON(t, DetailedWorkflow{ID: "200", Name: "daily"}),
+				"GET /attempts/555/tasks": func(w http.ResponseWriter, req *http.Request) {
+					w.Write([]byte(tasks))
+				},
+				"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+					if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+						t.Errorf("failed to decode request: %s", err)
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					respondJSON(t, Attempt{ID: "556"})(w, req)
+				},
+			})
+			defer teardown()
+
+			attempt, err := client.RetryAttempt(context.Background(), "555", tc.opts)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attempt.ID != "556" {
+				t.Fatalf("attempt wrong. got=%+v", attempt)
+			}
+			if body.WorkflowId != tc.expectedBody.WorkflowId || !body.SessionTime.Equal(sessionTime) {
+				t.Fatalf("request body wrong. want=%+v, got=%+v", tc.expectedBody, body)
+			}
+			if (body.Resume == nil) != (tc.expectedBody.Resume == nil) ||
+				body.Resume != nil && *body.Resume != *tc.expectedBody.Resume {
+				t.Fatalf("resume wrong. want=%+v, got=%+v", tc.expectedBody.Resume, body.Resume)
+			}
+			if tc.expectedBody.RetryAttemptName != "" && body.RetryAttemptName != tc.expectedBody.RetryAttemptName || body.RetryAttemptName == "" {
+				t.Fatalf("retry attempt name wrong. got=%s", body.RetryAttemptName)
+			}
+			params := body.Params.(map[string]interface{})
+			if params["target"] != "users" {
+				t.Fatalf("original params were not kept. got=%+v", params)
+			}
+			if limit := tc.opts.Params["limit"]; limit != nil && params["limit"] != float64(20) {
+				t.Fatalf("params were not overridden. got=%+v", params)
+			}
+		})
+	}
+}
+
+func TestClient_KillAttemptAndWait(t *testing.T) {
+	tt := []struct {
+		name            string
+		pollsUntilDone  int
+		expectedTimeout bool
+	}{
+		{name: "canceled", pollsUntilDone: 2},
+		{name: "timeout", pollsUntilDone: -1, expectedTimeout: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			polls, killed := 0, false
+			client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+				"GET /attempts/555": func(w http.ResponseWriter, req *http.Request) {
+					attempt := Attempt{ID: "555", CancelRequested: killed}
+					if killed {
+						polls++
+						attempt.Done = tc.pollsUntilDone >= 0 && polls >= tc.pollsUntilDone
+					}
+					respondJSON(t, attempt)(w, req)
+				},
+				"POST /attempts/555/kill": func(w http.ResponseWriter, req *http.Request) {
+					killed = true
+				},
+				"GET /attempts/555/tasks": func(w http.ResponseWriter, req *http.Request) {
+					w.Write([]byte(`{"tasks": [
+						{"fullName": "+daily", "state": "planned", "isGroup": true},
+						{"fullName": "+daily+load", "state": "canceled"},
+						{"fullName": "+daily^error+notify", "state": "running"}
+					]}`))
+				},
+			})
+			defer teardown()
+
+			attempt, err := client.KillAttemptAndWait(context.Background(), "555", KillWaitOptions{Timeout: 50 * time.Millisecond, PollInterval: time.Millisecond})
+			if !killed {
+				t.Fatal("attempt was not killed")
+			}
+			if tc.expectedTimeout {
+				var timeoutErr *KillTimeoutError
+				if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrKillTimeout) {
+					t.Fatalf("expected KillTimeoutError, got %v", err)
+				}
+				if len(timeoutErr.RunningTasks) != 1 || timeoutErr.RunningTasks[0].FullName != "+daily^error+notify" {
+					t.Fatalf("running tasks wrong. got=%+v", timeoutErr.RunningTasks)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !attempt.Done || !attempt.CancelRequested {
+				t.Fatalf("attempt must be done and canceled. got=%+v", attempt)
+			}
+		})
+	}
+}
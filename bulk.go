@@ -0,0 +1,214 @@
+package digdaggo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempt statuses as reported by the server.
+const (
+	AttemptRunning = "running"
+	AttemptSuccess = "success"
+	AttemptError   = "error"
+	AttemptKilled  = "killed"
+)
+
+// AttemptFilter selects attempts for bulk operations. Zero fields match everything.
+// Time ranges include From and exclude To.
+type AttemptFilter struct {
+	Project  string
+	Workflow string
+	// Status matches any of the given statuses, e.g. AttemptRunning.
+	Status      []string
+	SessionFrom time.Time
+	SessionTo   time.Time
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// IncludeRetried also matches attempts which have been retried since.
+	IncludeRetried bool
+}
+
+// Match reports whether the attempt passes the filter.
+func (f AttemptFilter) Match(a *Attempt) bool {
+	if f.Project != "" && a.Project.Name != f.Project {
+		return false
+	}
+	if f.Workflow != "" && a.Workflow.Name != f.Workflow {
+		return false
+	}
+	if len(f.Status) > 0 {
+		status, ok := attemptStatus(a), false
+		for _, s := range f.Status {
+			if s == status {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return inRange(a.SessionTime, f.SessionFrom, f.SessionTo) && inRange(a.CreatedAt, f.CreatedFrom, f.CreatedTo)
+}
+
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// attemptStatus returns the status of the attempt, derived from its flags when the server didn't send one.
+func attemptStatus(a *Attempt) string {
+	if a.Status != "" {
+		return a.Status
+	}
+	switch {
+	case !a.Done:
+		return AttemptRunning
+	case a.Success:
+		return AttemptSuccess
+	case a.CancelRequested:
+		return AttemptKilled
+	default:
+		return AttemptError
+	}
+}
+
+// FindAttempts lists the attempts matching the filter, newest first.
+func (c *Client) FindAttempts(ctx context.Context, filter AttemptFilter) ([]Attempt, error) {
+	var attempts []Attempt
+	err := c.forEachAttempt(ctx, filter.Project, filter.Workflow, filter.IncludeRetried, func(a *Attempt) (bool, error) {
+		if !filter.CreatedFrom.IsZero() && a.CreatedAt.Before(filter.CreatedFrom) {
+			return false, nil
+		}
+		if filter.Match(a) {
+			attempts = append(attempts, *a)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// BulkOptions controls how a bulk operation runs.
+type BulkOptions struct {
+	// DryRun only reports what would be done.
+	DryRun bool
+	// Concurrency bounds the number of requests in flight. Defaults to 4.
+	Concurrency int
+}
+
+// Bulk actions reported in a BulkResult.
+const (
+	BulkKilled  = "killed"
+	BulkRetried = "retried"
+	BulkSkipped = "skipped"
+	BulkPlanned = "planned"
+	BulkFailed  = "failed"
+)
+
+// BulkResult is the outcome of a bulk operation for one attempt.
+type BulkResult struct {
+	Attempt Attempt
+	Action  string
+	// RetryAttemptID is the ID of the new attempt of a retry.
+	RetryAttemptID string
+	// Reason tells why the attempt was skipped.
+	Reason string
+	Err    error
+}
+
+// BulkReport is the per-attempt report of a bulk operation, in the order the attempts were found.
+type BulkReport struct {
+	DryRun  bool
+	Results []BulkResult
+}
+
+// Count returns the number of results with the given action.
+func (r *BulkReport) Count(action string) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// BulkKill kills every running attempt matching the filter.
+func (c *Client) BulkKill(ctx context.Context, filter AttemptFilter, opts BulkOptions) (*BulkReport, error) {
+	return c.bulk(ctx, filter, opts, func(ctx context.Context, a *Attempt) BulkResult {
+		if a.Done {
+			return BulkResult{Action: BulkSkipped, Reason: "attempt is already done"}
+		}
+		if opts.DryRun {
+			return BulkResult{Action: BulkPlanned}
+		}
+		if err := c.KillAttempt(ctx, a.ID); err != nil {
+			return BulkResult{Action: BulkFailed, Err: err}
+		}
+		return BulkResult{Action: BulkKilled}
+	})
+}
+
+// BulkRetry retries every failed attempt matching the filter with the given retry options.
+// Killed attempts are skipped unless filter.Status includes AttemptKilled.
+func (c *Client) BulkRetry(ctx context.Context, filter AttemptFilter, retry RetryOptions, opts BulkOptions) (*BulkReport, error) {
+	retryKilled := containsString(filter.Status, AttemptKilled)
+	return c.bulk(ctx, filter, opts, func(ctx context.Context, a *Attempt) BulkResult {
+		if !a.Done || a.Success {
+			return BulkResult{Action: BulkSkipped, Reason: "attempt did not fail"}
+		}
+		if (a.CancelRequested || attemptStatus(a) == AttemptKilled) && !retryKilled {
+			return BulkResult{Action: BulkSkipped, Reason: "attempt was killed"}
+		}
+		if opts.DryRun {
+			return BulkResult{Action: BulkPlanned}
+		}
+		retried, err := c.RetryAttempt(ctx, a.ID, retry)
+		if err != nil {
+			return BulkResult{Action: BulkFailed, Err: err}
+		}
+		return BulkResult{Action: BulkRetried, RetryAttemptID: retried.ID}
+	})
+}
+
+func (c *Client) bulk(ctx context.Context, filter AttemptFilter, opts BulkOptions, do func(context.Context, *Attempt) BulkResult) (*BulkReport, error) {
+	attempts, err := c.FindAttempts(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	report := &BulkReport{DryRun: opts.DryRun, Results: make([]BulkResult, len(attempts))}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			var result BulkResult
+			if err := ctx.Err(); err != nil {
+				result = BulkResult{Action: BulkFailed, Err: err}
+			} else {
+				result = do(ctx, &attempts[i])
+			}
+			result.Attempt = attempts[i]
+			report.Results[i] = result
+		}(i)
+	}
+	wg.Wait()
+	return report, nil
+}
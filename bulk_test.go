@@ -0,0 +1,189 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAttemptFilter_Match(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00Z")
+	attempt := Attempt{
+		Project:     ProjectInAttempt{Name: "test"},
+		Workflow:    WorkflowInAttempt{Name: "daily"},
+		Done:        true,
+		SessionTime: base,
+		CreatedAt:   base.Add(time.Hour),
+	}
+	tt := []struct {
+		name   string
+		filter AttemptFilter
+		match  bool
+	}{
+		{name: "empty filter", filter: AttemptFilter{}, match: true},
+		{name: "other project", filter: AttemptFilter{Project: "other"}, match: false},
+		{name: "derived status", filter: AttemptFilter{Status: []string{AttemptRunning, AttemptError}}, match: true},
+		{name: "status mismatch", filter: AttemptFilter{Status: []string{AttemptSuccess}}, match: false},
+		{name: "session range includes from", filter: AttemptFilter{SessionFrom: base, SessionTo: base.Add(time.Hour)}, match: true},
+		{name: "session range excludes to", filter: AttemptFilter{SessionTo: base}, match: false},
+		{name: "created range", filter: AttemptFilter{CreatedFrom: base.Add(2 * time.Hour)}, match: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.Match(&attempt); got != tc.match {
+				t.Fatalf("match wrong. want=%v, got=%v", tc.match, got)
+			}
+		})
+	}
+}
+
+func TestClient_BulkKill(t *testing.T) {
+	attempts := []Attempt{
+		{ID: "3", Project: ProjectInAttempt{Name: "test"}, Status: AttemptRunning},
+		{ID: "2", Project: ProjectInAttempt{Name: "test"}, Status: AttemptSuccess, Done: true, Success: true},
+		{ID: "1", Project: ProjectInAttempt{Name: "test"}, Status: AttemptRunning},
+	}
+	var mu sync.Mutex
+	killed := map[string]bool{}
+	kill := func(id string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			killed[id] = true
+		}
+	}
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("project") != "test" {
+				t.Errorf("project filter was not sent. got=%s", req.URL.RawQuery)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: attempts})(w, req)
+		},
+		"POST /attempts/3/kill": kill("3"),
+		"POST /attempts/1/kill": kill("1"),
+	})
+	defer teardown()
+
+	filter := AttemptFilter{Project: "test"}
+	preview, err := client.BulkKill(context.Background(), filter, BulkOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Count(BulkPlanned) != 2 || preview.Count(BulkSkipped) != 1 || len(killed) != 0 {
+		t.Fatalf("dry run wrong. got=%+v, killed=%v", preview.Results, killed)
+	}
+
+	report, err := client.BulkKill(context.Background(), filter, BulkOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(BulkKilled) != 2 || !killed["1"] || !killed["3"] {
+		t.Fatalf("kill wrong. got=%+v, killed=%v", report.Results, killed)
+	}
+	if report.Results[0].Attempt.ID != "3" || report.Results[1].Action != BulkSkipped {
+		t.Fatalf("report must keep the order of the attempts. got=%+v", report.Results)
+	}
+}
+
+func TestClient_BulkRetry(t *testing.T) {
+	attempts := []Attempt{
+		{ID: "4", Workflow: WorkflowInAttempt{ID: "100"}, Done: true},
+		{ID: "3", Workflow: WorkflowInAttempt{ID: "100"}, Done: true, CancelRequested: true},
+		{ID: "2", Workflow: WorkflowInAttempt{ID: "100"}, Done: true},
+		{ID: "1", Workflow: WorkflowInAttempt{ID: "100"}, Done: true, Success: true},
+	}
+	getAttempt := func(a Attempt) http.HandlerFunc {
+		return respondJSON(t, a)
+	}
+	tt := []struct {
+		name    string
+		filter  AttemptFilter
+		dryRun  bool
+		actions []string
+		retried []string
+	}{
+		{
+			name:    "dry run",
+			dryRun:  true,
+			actions: []string{BulkPlanned, BulkSkipped, BulkPlanned, BulkSkipped},
+		},
+		{
+			name:    "retried",
+			actions: []string{BulkRetried, BulkSkipped, BulkFailed, BulkSkipped},
+			retried: []string{"4"},
+		},
+		{
+			name:    "killed attempts when asked for",
+			filter:  AttemptFilter{Status: []string{AttemptError, AttemptKilled}},
+			actions: []string{BulkRetried, BulkRetried, BulkFailed},
+			retried: []string{"3", "4"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			puts := 0
+			client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+				"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+					if req.URL.Query().Get("last_id") != "" {
+						respondJSON(t, AttemptList{})(w, req)
+						return
+					}
+					respondJSON(t, AttemptList{Attempts: attempts})(w, req)
+				},
+				"GET /attempts/4": getAttempt(attempts[0]),
+				"GET /attempts/3": getAttempt(attempts[1]),
+				"GET /attempts/2": func(w http.ResponseWriter, req *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				},
+				"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+					var body RetryAttemptBody
+					if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+						t.Errorf("failed to decode retry: %s", err)
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					puts++
+					respondJSON(t, Attempt{ID: "10"})(w, req)
+				},
+			})
+			defer teardown()
+
+			report, err := client.BulkRetry(context.Background(), tc.filter, RetryOptions{}, BulkOptions{DryRun: tc.dryRun})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actions, retried []string
+			for _, result := range report.Results {
+				actions = append(actions, result.Action)
+				if result.Action == BulkRetried {
+					retried = append(retried, result.Attempt.ID)
+				}
+				if result.Action == BulkSkipped && result.Attempt.ID == "3" && result.Reason != "attempt was killed" {
+					t.Errorf("reason wrong. got=%s", result.Reason)
+				}
+				if result.Action == BulkFailed && result.Err == nil {
+					t.Errorf("failed retry of attempt %s has no error", result.Attempt.ID)
+				}
+			}
+			if !reflect.DeepEqual(actions, tc.actions) {
+				t.Fatalf("actions wrong. got=%v", actions)
+			}
+			sort.Strings(retried)
+			if !reflect.DeepEqual(retried, tc.retried) || puts != len(tc.retried) {
+				t.Fatalf("retries wrong. got=%v, requests=%d", retried, puts)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ type Client struct {
 	HTTPClient *http.Client
 	Token      string
 	Logger     *log.Logger
+
+	workflows workflowCache
 }
 
 var (
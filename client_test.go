@@ -1 +1,47 @@
 package digdaggo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// setupRoutes starts a mock server which dispatches on "METHOD /path".
+// Requests which don't match any route fail the test.
+func setupRoutes(t *testing.T, routes map[string]http.HandlerFunc) (*Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handler, ok := routes[req.Method+" "+req.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handler(w, req)
+	}))
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to get mock server URL: %s", err.Error())
+	}
+
+	cli := &Client{
+		BaseURL:    serverURL,
+		HTTPClient: server.Client(),
+		Logger:     nil,
+	}
+	return cli, server.Close
+}
+
+func respondJSON(t *testing.T, body interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		res, err := json.Marshal(body)
+		if err != nil {
+			t.Errorf("failed to encode response: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(res)
+	}
+}
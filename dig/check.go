@@ -0,0 +1,262 @@
+package dig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operators are the operator types Check accepts.
+var Operators = []string{
+	"bq", "bq_ddl", "bq_extract", "bq_load", "call", "echo", "embulk", "emr", "fail", "for_each", "for_range",
+	"gcs_wait", "http", "http_call", "if", "loop", "mail", "param_get", "param_reset", "param_set", "pg", "py",
+	"rb", "redshift", "redshift_load", "redshift_unload", "require", "s3_wait", "sh", "sla", "td", "td_ddl",
+	"td_for_each", "td_load", "td_partial_delete", "td_result_export", "td_run", "td_table_export", "td_wait",
+	"td_wait_table", "wait",
+}
+
+// sqlOperators take the path of a SQL file as their command.
+var sqlOperators = map[string]bool{"td": true, "td_for_each": true, "td_wait": true, "pg": true, "redshift": true, "bq": true}
+
+// Diagnostic is a problem found in a project.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// CheckOptions configures Check.
+type CheckOptions struct {
+	// Operators are accepted in addition to the built-in ones, e.g. for plugins.
+	Operators []string
+}
+
+// Check validates a project like digdag check does. It loads the workflows at the root of the project
+// file system and the workflows they call>, and reports files which can't be parsed or included, missing
+// call> targets and SQL or script files, duplicate task names, invalid schedules, unknown operators and
+// invalid _retry values. Use os.DirFS to check a project directory.
+func Check(fsys fs.FS, opts CheckOptions) []Diagnostic {
+	c := &checker{fsys: fsys, operators: map[string]bool{}, loaded: map[string]bool{}, seen: map[Diagnostic]bool{}}
+	for _, op := range Operators {
+		c.operators[op] = true
+	}
+	for _, op := range opts.Operators {
+		c.operators[op] = true
+	}
+	files, err := fs.Glob(fsys, "*.dig")
+	if err != nil {
+		return []Diagnostic{{File: ".", Message: err.Error()}}
+	}
+	for _, file := range files {
+		c.load(file)
+	}
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		a, b := c.diagnostics[i], c.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return c.diagnostics
+}
+
+type checker struct {
+	fsys        fs.FS
+	operators   map[string]bool
+	loaded      map[string]bool
+	seen        map[Diagnostic]bool
+	diagnostics []Diagnostic
+}
+
+func (c *checker) report(file string, line int, format string, args ...interface{}) {
+	d := Diagnostic{File: file, Line: line, Message: fmt.Sprintf(format, args...)}
+	if !c.seen[d] {
+		c.seen[d] = true
+		c.diagnostics = append(c.diagnostics, d)
+	}
+}
+
+func (c *checker) load(file string) {
+	if c.loaded[file] {
+		return
+	}
+	c.loaded[file] = true
+	wf, err := Load(c.fsys, file)
+	if err != nil {
+		var digErr *Error
+		if errors.As(err, &digErr) {
+			c.report(digErr.File, digErr.Line, "%s", digErr.Msg)
+		} else {
+			c.report(file, 0, "%s", err)
+		}
+		return
+	}
+	if wf.Schedule != nil {
+		if err := checkSchedule(wf.Schedule); err != nil {
+			c.report(wf.File, wf.Schedule.Line, "invalid schedule: %s", err)
+		}
+	}
+	dir := path.Dir(file)
+	wf.Root.Walk(func(t *Task) error {
+		c.task(dir, t)
+		return nil
+	})
+}
+
+func (c *checker) task(dir string, t *Task) {
+	names := map[string]bool{}
+	for _, sub := range t.Tasks {
+		if names[sub.Name] {
+			c.report(sub.File, sub.Line, "duplicate task name %s in %s", sub.Name, t.FullName)
+		}
+		names[sub.Name] = true
+	}
+	if t.Retry != nil {
+		if err := checkRetry(t.Retry); err != nil {
+			c.report(t.File, t.Retry.Line, "invalid _retry: %s", err)
+		}
+	}
+	if t.Operator == "" {
+		return
+	}
+	line := t.KeyLine(t.Operator + ">")
+	if !c.operators[t.Operator] {
+		c.report(t.File, line, "unknown operator %s>", t.Operator)
+		return
+	}
+	command, ok := t.Command.(string)
+	if !ok || isExpr(command) {
+		return
+	}
+	switch {
+	case t.Operator == "call":
+		target := path.Join(dir, command)
+		if !strings.HasSuffix(target, ".dig") {
+			target += ".dig"
+		}
+		if !c.exists(target) {
+			c.report(t.File, line, "call> target %s does not exist", command)
+			return
+		}
+		c.load(target)
+	case sqlOperators[t.Operator] && strings.HasSuffix(command, ".sql"):
+		if !c.exists(path.Join(dir, command)) {
+			c.report(t.File, line, "SQL file %s does not exist", command)
+		}
+	case t.Operator == "sh":
+		script := strings.Fields(command)
+		if len(script) > 0 && (strings.HasSuffix(script[0], ".sh") || strings.HasPrefix(script[0], "./")) {
+			if !c.exists(path.Join(dir, script[0])) {
+				c.report(t.File, line, "script %s does not exist", script[0])
+			}
+		}
+	case t.Operator == "py":
+		if !c.pythonModuleExists(dir, command) {
+			c.report(t.File, line, "python module of %s does not exist", command)
+		}
+	}
+}
+
+func (c *checker) exists(name string) bool {
+	if !fs.ValidPath(name) {
+		return false
+	}
+	info, err := fs.Stat(c.fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// pythonModuleExists looks for the module of a py> command such as tasks.Report.run or scripts/report.py.
+func (c *checker) pythonModuleExists(dir, command string) bool {
+	if strings.HasSuffix(command, ".py") {
+		return c.exists(path.Join(dir, command))
+	}
+	parts := strings.Split(command, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		module := path.Join(dir, path.Join(parts[:i]...))
+		if c.exists(module+".py") || c.exists(path.Join(module, "__init__.py")) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	weekdays = map[string]bool{
+		"sun": true, "mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true,
+		"sunday": true, "monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true,
+	}
+	clockPattern = regexp.MustCompile(`^(\d{1,2}):(\d{2}):(\d{2})$`)
+)
+
+func checkSchedule(s *Schedule) error {
+	value := strings.TrimSpace(s.Value)
+	if isExpr(value) {
+		return nil
+	}
+	switch s.Kind {
+	case "hourly":
+		return checkClock("00:" + value)
+	case "daily":
+		return checkClock(value)
+	case "weekly":
+		day, clock, ok := strings.Cut(value, ",")
+		if !ok || !weekdays[strings.ToLower(strings.TrimSpace(day))] {
+			return fmt.Errorf("weekly> needs DAY,HH:MM:SS, got %q", value)
+		}
+		return checkClock(strings.TrimSpace(clock))
+	case "monthly":
+		day, clock, ok := strings.Cut(value, ",")
+		n, err := strconv.Atoi(strings.TrimSpace(day))
+		if !ok || err != nil || n < 1 || n > 31 {
+			return fmt.Errorf("monthly> needs D,HH:MM:SS with D from 1 to 31, got %q", value)
+		}
+		return checkClock(strings.TrimSpace(clock))
+	case "minutes_interval":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("minutes_interval> needs a positive number, got %q", value)
+		}
+		return nil
+	case "cron":
+		_, err := parseCron(value)
+		return err
+	}
+	return fmt.Errorf("unknown schedule %s>", s.Kind)
+}
+
+func checkClock(value string) error {
+	m := clockPattern.FindStringSubmatch(value)
+	if m == nil {
+		return fmt.Errorf("%q is not a time of day", value)
+	}
+	h, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	if h > 23 || minute > 59 || sec > 59 {
+		return fmt.Errorf("%q is not a time of day", value)
+	}
+	return nil
+}
+
+func checkRetry(r *Retry) error {
+	switch {
+	case r.Limit < 0:
+		return fmt.Errorf("limit must not be negative")
+	case r.Interval < 0 || r.MaxInterval < 0:
+		return fmt.Errorf("intervals must not be negative")
+	case r.IntervalType != "" && r.IntervalType != "constant" && r.IntervalType != "exponential":
+		return fmt.Errorf("interval_type must be constant or exponential, got %q", r.IntervalType)
+	case r.MaxInterval > 0 && r.Interval > r.MaxInterval:
+		return fmt.Errorf("interval is larger than max_interval")
+	}
+	return nil
+}
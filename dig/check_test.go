@@ -0,0 +1,95 @@
+package dig
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheck(t *testing.T) {
+	fsys := fstest.MapFS{
+		"daily.dig": {Data: []byte(`schedule:
+  daily>: 25:00:00
++load:
+  td>: queries/load.sql
++missing_sql:
+  td>: queries/missing.sql
++dup:
+  echo>: a
++dup:
+  echo>: b
++custom:
+  my_plugin>: x
++retry:
+  _retry:
+    limit: 3
+    interval_type: linear
+  sh>: scripts/run.sh
++sub:
+  call>: sub/child
++py:
+  py>: tasks.report.Report.run
++py_missing:
+  py>: tasks.nope.run
+`)},
+		"hourly.dig":               {Data: []byte("schedule:\n  cron>: '*/5 * * * *'\n+a:\n  call>: nope.dig\n+b:\n  !include : 'tasks/missing.dig'\n")},
+		"sub/child.dig":            {Data: []byte("+a:\n  td>: queries/child.sql\n+b:\n  sh>: ./missing.sh arg\n")},
+		"sub/queries/child.sql":    {Data: []byte("select 1")},
+		"queries/load.sql":         {Data: []byte("select 1")},
+		"scripts/run.sh":           {Data: []byte("echo")},
+		"tasks/report/__init__.py": {Data: []byte("")},
+		"broken.dig":               {Data: []byte("+a:\n\techo>: a\n")},
+	}
+	diagnostics := Check(fsys, CheckOptions{})
+	want := []Diagnostic{
+		{File: "broken.dig", Line: 2, Message: "found character that cannot start any token"},
+		{File: "daily.dig", Line: 1, Message: `invalid schedule: "25:00:00" is not a time of day`},
+		{File: "daily.dig", Line: 6, Message: "SQL file queries/missing.sql does not exist"},
+		{File: "daily.dig", Line: 9, Message: "duplicate task name +dup in +daily"},
+		{File: "daily.dig", Line: 12, Message: "unknown operator my_plugin>"},
+		{File: "daily.dig", Line: 14, Message: `invalid _retry: interval_type must be constant or exponential, got "linear"`},
+		{File: "daily.dig", Line: 23, Message: "python module of tasks.nope.run does not exist"},
+		{File: "hourly.dig", Line: 6, Message: "!include tasks/missing.dig: open tasks/missing.dig: file does not exist"},
+		{File: "sub/child.dig", Line: 4, Message: "script ./missing.sh does not exist"},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("diagnostics wrong. got=%v", diagnostics)
+	}
+	for i := range want {
+		if diagnostics[i] != want[i] {
+			t.Errorf("diagnostic wrong. want=%v, got=%v", want[i], diagnostics[i])
+		}
+	}
+
+	diagnostics = Check(fsys, CheckOptions{Operators: []string{"my_plugin"}})
+	for _, d := range diagnostics {
+		if d.Message == "unknown operator my_plugin>" {
+			t.Errorf("plugin operator reported: %v", d)
+		}
+	}
+}
+
+func TestCheckSchedule(t *testing.T) {
+	tests := []struct {
+		kind, value string
+		valid       bool
+	}{
+		{"hourly", "30:00", true},
+		{"hourly", "61:00", false},
+		{"daily", "07:00:00", true},
+		{"weekly", "Sun,09:00:00", true},
+		{"weekly", "Someday,09:00:00", false},
+		{"monthly", "1,09:00:00", true},
+		{"monthly", "32,09:00:00", false},
+		{"minutes_interval", "30", true},
+		{"minutes_interval", "0", false},
+		{"cron", "0 */2 * * 1-5", true},
+		{"cron", "0 * * *", false},
+		{"yearly", "01-01", false},
+	}
+	for _, tt := range tests {
+		err := checkSchedule(&Schedule{Kind: tt.kind, Value: tt.value})
+		if (err == nil) != tt.valid {
+			t.Errorf("%s> %s: got=%v", tt.kind, tt.value, err)
+		}
+	}
+}
@@ -0,0 +1,845 @@
+package dig
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Func is a function which expressions can call, such as moment.
+type Func func(args ...interface{}) (interface{}, error)
+
+// Eval evaluates a ${...} expression without the braces, e.g. moment(session_time).format("YYYYMMDD").
+// Expressions are the subset of JavaScript which workflows commonly use: literals, variables, member and
+// index access, function and method calls, arithmetic, comparison, logical operators and the conditional
+// operator. moment and JSON.stringify are available in addition to vars.
+func Eval(expr string, vars map[string]interface{}) (interface{}, error) {
+	p := &exprParser{src: expr}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", p.tok.text, p.tok.pos)
+	}
+	return node.eval(vars)
+}
+
+// Render replaces the ${...} expressions of a template with their values, like Digdag does for the
+// strings of a workflow and the files of SQL operators.
+func Render(template string, vars map[string]interface{}) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(template, "${")
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		b.WriteString(template[:start])
+		end, err := exprEnd(template, start+2)
+		if err != nil {
+			return "", err
+		}
+		value, err := Eval(template[start+2:end], vars)
+		if err != nil {
+			return "", fmt.Errorf("${%s}: %w", template[start+2:end], err)
+		}
+		b.WriteString(toTemplateString(value))
+		template = template[end+1:]
+	}
+}
+
+// RenderPartial is like Render, but keeps the expressions which can't be evaluated as written, such as
+// references to variables which are only known when the task runs.
+func RenderPartial(template string, vars map[string]interface{}) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(template, "${")
+		if start < 0 {
+			b.WriteString(template)
+			return b.String()
+		}
+		b.WriteString(template[:start])
+		end, err := exprEnd(template, start+2)
+		if err != nil {
+			b.WriteString(template[start:])
+			return b.String()
+		}
+		if value, err := Eval(template[start+2:end], vars); err == nil {
+			b.WriteString(toTemplateString(value))
+		} else {
+			b.WriteString(template[start : end+1])
+		}
+		template = template[end+1:]
+	}
+}
+
+// exprEnd finds the closing brace of an expression, skipping string literals and nested braces.
+func exprEnd(s string, i int) (int, error) {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			quote := s[i]
+			for i++; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("unterminated ${ expression")
+}
+
+// toTemplateString formats a value like Digdag does when it's embedded into a string.
+func toTemplateString(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return toString(v)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// punctuators in the order they're matched, longest first.
+var punctuators = []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "?", ":", ".", ",", "(", ")", "[", "]"}
+
+type exprParser struct {
+	src string
+	pos int
+	tok token
+}
+
+func (p *exprParser) next() error {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+	ch := p.src[p.pos]
+	switch {
+	case ch >= '0' && ch <= '9':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			exp := p.pos + 1
+			if exp < len(p.src) && (p.src[exp] == '+' || p.src[exp] == '-') {
+				exp++
+			}
+			if exp < len(p.src) && p.src[exp] >= '0' && p.src[exp] <= '9' {
+				for p.pos = exp; p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9'; p.pos++ {
+				}
+			}
+		}
+		num, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], num: num, pos: start}
+	case ch == '\'' || ch == '"':
+		var b strings.Builder
+		for p.pos++; ; p.pos++ {
+			if p.pos >= len(p.src) {
+				return fmt.Errorf("unterminated string at %d", start)
+			}
+			c := p.src[p.pos]
+			if c == ch {
+				p.pos++
+				break
+			}
+			if c == '\\' && p.pos+1 < len(p.src) {
+				p.pos++
+				switch p.src[p.pos] {
+				case 'n':
+					c = '\n'
+				case 't':
+					c = '\t'
+				default:
+					c = p.src[p.pos]
+				}
+			}
+			b.WriteByte(c)
+		}
+		p.tok = token{kind: tokString, text: b.String(), pos: start}
+	case ch == '_' || ch == '$' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z':
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+				p.pos++
+				continue
+			}
+			break
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		for _, punct := range punctuators {
+			if strings.HasPrefix(p.src[p.pos:], punct) {
+				p.pos += len(punct)
+				p.tok = token{kind: tokPunct, text: punct, pos: start}
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected character %q at %d", ch, start)
+	}
+	return nil
+}
+
+func (p *exprParser) is(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+func (p *exprParser) expect(punct string) error {
+	if !p.is(punct) {
+		return fmt.Errorf("expected %q at %d", punct, p.tok.pos)
+	}
+	return p.next()
+}
+
+// binary operator precedence, higher binds tighter
+var precedence = map[string]int{
+	"||": 1, "&&": 2,
+	"==": 3, "!=": 3, "===": 3, "!==": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	cond, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("?") {
+		return cond, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{cond, then, otherwise}, nil
+}
+
+func (p *exprParser) parseBinary(minPrec int) (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokPunct {
+		op := p.tok.text
+		prec, ok := precedence[op]
+		if !ok || prec < minPrec {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.is("!") || p.is("-") || p.is("+") {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op, x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected a name at %d", p.tok.pos)
+			}
+			node = &memberNode{node, &literalNode{p.tok.text}}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.is("["):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &memberNode{node, index}
+		case p.is("("):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			var args []exprNode
+			for !p.is(")") {
+				arg, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if !p.is(",") {
+					break
+				}
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			node = &callNode{node, args}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{tok.num}, p.next()
+	case tokString:
+		return &literalNode{tok.text}, p.next()
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{tok.text == "true"}, p.next()
+		case "null", "undefined":
+			return &literalNode{nil}, p.next()
+		}
+		return &identNode{tok.text}, p.next()
+	case tokPunct:
+		if tok.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			node, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+type exprNode interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+type identNode struct{ name string }
+
+type memberNode struct{ object, key exprNode }
+
+type callNode struct {
+	fn   exprNode
+	args []exprNode
+}
+
+type unaryNode struct {
+	op string
+	x  exprNode
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+type condNode struct{ cond, then, otherwise exprNode }
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(vars map[string]interface{}) (interface{}, error) {
+	if v, ok := vars[n.name]; ok {
+		return normalize(v), nil
+	}
+	if v, ok := globals[n.name]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s is not defined", n.name)
+}
+
+func (n *memberNode) eval(vars map[string]interface{}) (interface{}, error) {
+	object, err := n.object.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return member(object, key)
+}
+
+func member(object, key interface{}) (interface{}, error) {
+	switch o := object.(type) {
+	case map[string]interface{}:
+		return normalize(o[toString(key)]), nil
+	case []interface{}:
+		if toString(key) == "length" {
+			return float64(len(o)), nil
+		}
+		i := int(toNumber(key))
+		if i < 0 || i >= len(o) {
+			return nil, nil
+		}
+		return normalize(o[i]), nil
+	case string:
+		if toString(key) == "length" {
+			return float64(len([]rune(o))), nil
+		}
+	case nil:
+		return nil, fmt.Errorf("cannot read property %s of null", toString(key))
+	}
+	return nil, nil
+}
+
+func (n *callNode) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	if m, ok := n.fn.(*memberNode); ok {
+		object, err := m.object.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		key, err := m.key.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		name := toString(key)
+		switch o := object.(type) {
+		case *Moment:
+			return o.call(name, args)
+		case string:
+			return stringMethod(o, name, args)
+		case []interface{}:
+			if name == "join" {
+				sep := ","
+				if len(args) > 0 {
+					sep = toString(args[0])
+				}
+				parts := make([]string, len(o))
+				for i, item := range o {
+					parts[i] = toString(normalize(item))
+				}
+				return strings.Join(parts, sep), nil
+			}
+		}
+		fn, err := member(object, key)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := fn.(Func); ok {
+			return f(args...)
+		}
+		return nil, fmt.Errorf("%s is not a function", name)
+	}
+	fn, err := n.fn.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fn.(Func)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a function", fn)
+	}
+	return f(args...)
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		return !truthy(x), nil
+	case "-":
+		return -toNumber(x), nil
+	}
+	return toNumber(x), nil
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return left, nil
+		}
+		return n.right.eval(vars)
+	case "||":
+		if truthy(left) {
+			return left, nil
+		}
+		return n.right.eval(vars)
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		_, ls := left.(string)
+		_, rs := right.(string)
+		if ls || rs {
+			return toString(left) + toString(right), nil
+		}
+		return toNumber(left) + toNumber(right), nil
+	case "-":
+		return toNumber(left) - toNumber(right), nil
+	case "*":
+		return toNumber(left) * toNumber(right), nil
+	case "/":
+		return toNumber(left) / toNumber(right), nil
+	case "%":
+		return math.Mod(toNumber(left), toNumber(right)), nil
+	case "===":
+		return strictEquals(left, right), nil
+	case "!==":
+		return !strictEquals(left, right), nil
+	case "==":
+		return looseEquals(left, right), nil
+	case "!=":
+		return !looseEquals(left, right), nil
+	}
+	var cmp int
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if lok && rok {
+		cmp = strings.Compare(ls, rs)
+	} else {
+		l, r := toNumber(left), toNumber(right)
+		if math.IsNaN(l) || math.IsNaN(r) {
+			return false, nil
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func (n *condNode) eval(vars map[string]interface{}) (interface{}, error) {
+	cond, err := n.cond.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return n.then.eval(vars)
+	}
+	return n.otherwise.eval(vars)
+}
+
+var globals = map[string]interface{}{
+	"moment": Func(newMoment),
+	"JSON": map[string]interface{}{
+		"stringify": Func(func(args ...interface{}) (interface{}, error) {
+			if len(args) == 0 {
+				return nil, nil
+			}
+			b, err := json.Marshal(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		}),
+		"parse": Func(func(args ...interface{}) (interface{}, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("JSON.parse needs an argument")
+			}
+			var v interface{}
+			if err := json.Unmarshal([]byte(toString(args[0])), &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		}),
+	},
+}
+
+func stringMethod(s, name string, args []interface{}) (interface{}, error) {
+	arg := func(i int) string {
+		if i < len(args) {
+			return toString(args[i])
+		}
+		return ""
+	}
+	switch name {
+	case "toUpperCase":
+		return strings.ToUpper(s), nil
+	case "toLowerCase":
+		return strings.ToLower(s), nil
+	case "trim":
+		return strings.TrimSpace(s), nil
+	case "replace":
+		return strings.Replace(s, arg(0), arg(1), 1), nil
+	case "split":
+		parts := strings.Split(s, arg(0))
+		items := make([]interface{}, len(parts))
+		for i, part := range parts {
+			items[i] = part
+		}
+		return items, nil
+	case "indexOf":
+		return float64(strings.Index(s, arg(0))), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg(0)), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg(0)), nil
+	case "substring", "slice":
+		runes := []rune(s)
+		start, end := 0, len(runes)
+		if len(args) > 0 {
+			start = int(toNumber(args[0]))
+		}
+		if len(args) > 1 {
+			end = int(toNumber(args[1]))
+		}
+		if name == "slice" {
+			if start < 0 {
+				start += len(runes)
+			}
+			if end < 0 {
+				end += len(runes)
+			}
+		}
+		start = clamp(start, 0, len(runes))
+		end = clamp(end, 0, len(runes))
+		if start > end {
+			if name == "slice" {
+				return "", nil
+			}
+			start, end = end, start
+		}
+		return string(runes[start:end]), nil
+	}
+	return nil, fmt.Errorf("string has no method %s", name)
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// normalize turns the numbers of decoded YAML and Go values into float64 like JavaScript has them.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case map[string]string:
+		m := make(map[string]interface{}, len(n))
+		for k, s := range n {
+			m[k] = s
+		}
+		return m
+	}
+	return v
+}
+
+func truthy(v interface{}) bool {
+	switch v := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func toNumber(v interface{}) float64 {
+	switch v := normalize(v).(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case float64:
+		return v
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case *Moment:
+		return float64(v.t.UnixMilli())
+	}
+	return math.NaN()
+}
+
+func toString(v interface{}) string {
+	switch v := normalize(v).(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		if math.IsInf(v, 0) {
+			if v > 0 {
+				return "Infinity"
+			}
+			return "-Infinity"
+		}
+		if math.IsNaN(v) {
+			return "NaN"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *Moment:
+		s, _ := v.format(defaultMomentFormat)
+		return s
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		return "[object Object]"
+	}
+	return fmt.Sprint(v)
+}
+
+func strictEquals(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	switch a := a.(type) {
+	case nil, bool, float64, string:
+		return a == b
+	}
+	return false
+}
+
+func looseEquals(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	_, as := a.(string)
+	_, bs := b.(string)
+	if as && bs {
+		return a == b
+	}
+	if isPrimitive(a) && isPrimitive(b) {
+		return toNumber(a) == toNumber(b)
+	}
+	return strictEquals(a, b)
+}
+
+func isPrimitive(v interface{}) bool {
+	switch v.(type) {
+	case bool, float64, string:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package dig
+
+import (
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"session_time": "2024-01-31T09:30:00+09:00",
+		"n":            3,
+		"name":         "orders",
+		"td":           map[string]interface{}{"database": "analytics"},
+		"regions":      []interface{}{"us", "eu"},
+		"empty":        "",
+	}
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"name", "orders"},
+		{"td.database", "analytics"},
+		{"td['database'] + '_tmp'", "analytics_tmp"},
+		{"n * 2 + 1", float64(7)},
+		{"n + '1'", "31"},
+		{"(n + 1) % 3", float64(1)},
+		{"-n", float64(-3)},
+		{"n > 2 && name == 'orders'", true},
+		{"n === '3'", false},
+		{"n == '3'", true},
+		{"empty || 'default'", "default"},
+		{"!empty", true},
+		{"n > 5 ? 'big' : 'small'", "small"},
+		{"regions.length", float64(2)},
+		{"regions[1]", "eu"},
+		{"regions.join('-')", "us-eu"},
+		{"name.toUpperCase()", "ORDERS"},
+		{"name.replace('ers', 'er')", "order"},
+		{"name.substring(1, 3)", "rd"},
+		{"JSON.stringify(td)", `{"database":"analytics"}`},
+		{"moment(session_time).format('YYYYMMDD')", "20240131"},
+		{"moment(session_time).add(1, 'months').format('YYYY-MM-DD')", "2024-02-29"},
+		{"moment(session_time).subtract(1, 'days').format('YYYY-MM-DD HH:mm:ss Z')", "2024-01-30 09:30:00 +09:00"},
+		{"moment(session_time).utc().format('YYYY-MM-DDTHH:mm:ss[Z]')", "2024-01-31T00:30:00Z"},
+		{"moment(session_time).startOf('month').format('Do MMM YYYY, dddd')", "1st Jan 2024, Monday"},
+		{"moment(session_time).endOf('day').format('HH:mm:ss.SSS')", "23:59:59.999"},
+		{"moment(session_time).unix()", float64(1706661000)},
+		{"moment('20240101', 'YYYYMMDD').format('YYYY-MM-DD')", "2024-01-01"},
+		{"moment(session_time).diff(moment('2024-01-01T09:30:00+09:00'), 'days')", float64(30)},
+		{"moment(session_time).isAfter('2024-01-01')", true},
+		{"moment(session_time).format('GGGG-[W]WW-E')", "2024-W05-3"},
+		{"moment('2024-12-30').format('GGGG [W]W, [Q]Q YYYY')", "2025 W1, Q4 2024"},
+		{"1e3 + 1", float64(1001)},
+		{"2.5E-1", 0.25},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expr, vars)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: want=%v (%T), got=%v (%T)", tt.expr, tt.want, tt.want, got, got)
+		}
+	}
+
+	for _, expr := range []string{"undefined_var", "name.", "(n", "n +", "'open", "n.foo()", "moment('someday')", "1e", "moment(session_time).format('gggg-ww')"} {
+		if _, err := Eval(expr, vars); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	vars := map[string]interface{}{"table": "orders", "days": 7, "opts": map[string]interface{}{"a": 1}}
+	got, err := Render("select * from ${table} where time > ${days * 86400} -- ${'}'} ${opts}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `select * from orders where time > 604800 -- } {"a":1}`; got != want {
+		t.Fatalf("render wrong. want=%q, got=%q", want, got)
+	}
+	if _, err := Render("${table", vars); err == nil {
+		t.Fatal("expected an error for an unterminated expression")
+	}
+	if _, err := Render("${missing}", vars); err == nil {
+		t.Fatal("expected an error for an undefined variable")
+	}
+	if got, want := RenderPartial("select * from ${table} where d = '${session_date}' -- ${table", vars), "select * from orders where d = '${session_date}' -- ${table"; got != want {
+		t.Fatalf("partial render wrong. want=%q, got=%q", want, got)
+	}
+}
@@ -0,0 +1,230 @@
+package dig
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// blankLineMarker is a comment which Format turns into a blank line.
+const blankLineMarker = "#digdag-fmt:blank"
+
+// directiveOrder ranks the keys of a task. Operator params and unknown keys rank between the operator and
+// _do; child tasks and !include directives come last in their original order.
+var directiveOrder = map[string]int{
+	"timezone": 0, "schedule": 1, "sla": 2,
+	"_export": 3, "_parallel": 4, "_background": 5, "_retry": 6,
+	// 7: the operator, 8: its params
+	"_do": 9, "_else_do": 10, "_check": 11, "_error": 12,
+}
+
+// yaml11Special matches plain scalars which YAML 1.1 parsers such as Digdag's don't read as strings.
+var yaml11Special = regexp.MustCompile(`^(?i:y|n|yes|no|on|off|true|false|null|~)$|^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$|^[-+]?(0x[0-9a-fA-F_]+|0b[01_]+|0[0-7_]+|[0-9][0-9_]*(\.[0-9_]*)?([eE][-+]?[0-9]+)?|\.(inf|Inf|INF|nan|NaN|NAN))$`)
+
+// Format formats a .dig file: two space indentation, task keys in canonical order (directives, operator,
+// params, _do, _check and _error, then child tasks), strings unquoted unless quotes are needed, single
+// quotes otherwise, and one blank line before each task which isn't first in its block. Comments are kept.
+func Format(src []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		// nothing but comments, such as a disabled workflow
+		return src, nil
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.MappingNode && len(root.Content) > 0 {
+		// a comment above the first key is the header of the file, which stays on top when the key moves
+		first := root.Content[0]
+		formatTask(root)
+		if root.Content[0] != first && first.HeadComment != "" {
+			doc.HeadComment = strings.TrimPrefix(doc.HeadComment+"\n"+first.HeadComment, "\n")
+			first.HeadComment = ""
+		}
+	}
+	normalizeScalars(&doc)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, line := range lines {
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == blankLineMarker:
+			lines[i] = ""
+		case strings.HasPrefix(trimmed, includeTag+" '':"):
+			lines[i] = strings.Replace(line, includeTag+" '':", includeTag+" :", 1)
+		}
+	}
+	return []byte(strings.Join(trimFoldedScalars(lines), "\n")), nil
+}
+
+// foldedHeader matches a line which starts a folded block scalar with clip chomping.
+var foldedHeader = regexp.MustCompile(`(^|: |- )>[1-9]?$`)
+
+// trimFoldedScalars drops the blank line the encoder writes after a folded block scalar, which clip
+// chomping drops from the value anyway.
+func trimFoldedScalars(lines []string) []string {
+	trimmed := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		trimmed = append(trimmed, lines[i])
+		if !foldedHeader.MatchString(strings.TrimSpace(lines[i])) {
+			continue
+		}
+		indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		end := i + 1
+		for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || len(lines[end])-len(strings.TrimLeft(lines[end], " ")) > indent) {
+			end++
+		}
+		block := lines[i+1 : end]
+		if len(block) > 1 && strings.TrimSpace(block[len(block)-1]) == "" {
+			block = block[:len(block)-1]
+		}
+		trimmed = append(trimmed, block...)
+		i = end - 1
+	}
+	return trimmed
+}
+
+// formatTask orders the keys of a task mapping and of its child tasks, and separates the child tasks.
+func formatTask(node *yaml.Node) {
+	type pair struct {
+		key, value *yaml.Node
+		rank       int
+	}
+	pairs := make([]pair, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		rank, known := directiveOrder[key.Value]
+		switch {
+		case isInclude(key) || strings.HasPrefix(key.Value, "+"):
+			rank = 13
+		case strings.HasSuffix(key.Value, ">"):
+			rank = 7
+		case !known:
+			rank = 8
+		}
+		if value.Kind == yaml.MappingNode && (rank == 13 && !isInclude(key) || rank >= 9) {
+			formatTask(value)
+		}
+		pairs = append(pairs, pair{key, value, rank})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].rank < pairs[j].rank })
+
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i, p := range pairs {
+		p.key.HeadComment = strings.TrimPrefix(p.key.HeadComment, blankLineMarker+"\n")
+		if p.key.HeadComment == blankLineMarker {
+			p.key.HeadComment = ""
+		}
+		if i > 0 && strings.HasPrefix(p.key.Value, "+") {
+			if p.key.HeadComment == "" {
+				p.key.HeadComment = blankLineMarker
+			} else {
+				p.key.HeadComment = blankLineMarker + "\n" + p.key.HeadComment
+			}
+		}
+		content = append(content, p.key, p.value)
+	}
+	node.Content = content
+}
+
+// normalizeScalars writes strings plain when they read back as the same string, in single quotes otherwise.
+func normalizeScalars(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && !isInclude(node) && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 && node.ShortTag() == "!!str" {
+		switch {
+		case strings.ContainsAny(node.Value, "\n\t\r") || !isPrintable(node.Value):
+			node.Style = yaml.DoubleQuotedStyle
+		case needsQuotes(node.Value):
+			node.Style = yaml.SingleQuotedStyle
+		default:
+			node.Style = 0
+		}
+	}
+	for _, child := range node.Content {
+		normalizeScalars(child)
+	}
+}
+
+func needsQuotes(s string) bool {
+	if s == "" || yaml11Special.MatchString(s) {
+		return true
+	}
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return true
+	}
+	return out[0] == '"' || out[0] == '\'' || out[0] == '|' || out[0] == '>'
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckFormat returns the .dig files of a project file system which aren't formatted, in order.
+func CheckFormat(fsys fs.FS) ([]string, error) {
+	var unformatted []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(name, ".dig") {
+			return err
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		formatted, err := Format(src)
+		if err != nil {
+			return &Error{File: name, Msg: err.Error()}
+		}
+		if !bytes.Equal(src, formatted) {
+			unformatted = append(unformatted, name)
+		}
+		return nil
+	})
+	return unformatted, err
+}
+
+// FormatDir formats the .dig files in a directory tree in place and returns the ones which changed.
+func FormatDir(dir string) ([]string, error) {
+	unformatted, err := CheckFormat(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range unformatted {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := Format(src)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, formatted, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+	}
+	return unformatted, nil
+}
@@ -0,0 +1,144 @@
+package dig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFormat(t *testing.T) {
+	src := `# nightly load
+_export:
+    td:
+      database: "analytics"
+    flag: 'on'
+timezone: "Asia/Tokyo"
+schedule:
+  daily>: '07:00:00'
++load:
+    # the main table
+    td>: queries/load.sql
+    create_table: "daily"
+    _retry: 3
++report:
+  _error:
+    echo>: failed
+  sh>: |
+    echo a
+    echo b
+  env: "x: y"
++params:
+  !include : 'config/params.yml'
++done:
+  echo>: "17:30"  # local time
+`
+	want := `# nightly load
+
+timezone: Asia/Tokyo
+schedule:
+  daily>: '07:00:00'
+_export:
+  td:
+    database: analytics
+  flag: 'on'
+
++load:
+  _retry: 3
+  # the main table
+  td>: queries/load.sql
+  create_table: daily
+
++report:
+  sh>: |
+    echo a
+    echo b
+  env: 'x: y'
+  _error:
+    echo>: failed
+
++params:
+  !include : config/params.yml
+
++done:
+  echo>: '17:30' # local time
+`
+	got, err := Format([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("Format wrong. got=\n%s", got)
+	}
+	again, err := Format(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != want {
+		t.Fatalf("Format is not idempotent. got=\n%s", again)
+	}
+
+	before, err := Parse("nightly.dig", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Parse("nightly.dig", got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before.Root.Export, after.Root.Export) || before.Root.Find("+nightly+done").Command != after.Root.Find("+nightly+done").Command {
+		t.Fatalf("Format changed the workflow")
+	}
+
+	for _, src := range []string{
+		"# +a:\n#   echo>: hi\n",
+		"+a:\n  echo>: >\n    hello\n\n+b:\n  echo>: >\n    world\n",
+	} {
+		if got, err := Format([]byte(src)); err != nil || string(got) != src {
+			t.Fatalf("Format(%q) wrong. got=%q, %v", src, got, err)
+		}
+	}
+
+	if _, err := Format([]byte("+a:\n\t- b\n")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ok.dig":         {Data: []byte("+a:\n  echo>: a\n\n+b:\n  echo>: b\n")},
+		"messy.dig":      {Data: []byte("+a:\n    echo>: a\n")},
+		"sub/quoted.dig": {Data: []byte("+a:\n  echo>: \"a\"\n")},
+		"disabled.dig":   {Data: []byte("# +a:\n#   echo>: a\n")},
+		"queries/a.sql":  {Data: []byte("select 1\n")},
+	}
+	got, err := CheckFormat(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"messy.dig", "sub/quoted.dig"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckFormat wrong. got=%v", got)
+	}
+
+	dir := t.TempDir()
+	for name, file := range fsys {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed, err := FormatDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, got) {
+		t.Fatalf("FormatDir wrong. got=%v", changed)
+	}
+	if rest, err := CheckFormat(os.DirFS(dir)); err != nil || len(rest) != 0 {
+		t.Fatalf("files left unformatted: %v %v", rest, err)
+	}
+}
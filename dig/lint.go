@@ -0,0 +1,301 @@
+package dig
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity is the severity of a lint finding.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	// SeverityOff disables a rule.
+	SeverityOff Severity = "off"
+)
+
+// LintRule checks a workflow for a convention.
+type LintRule struct {
+	Name        string
+	Description string
+	// Severity is the default severity of the rule's findings.
+	Severity Severity
+	Check    func(wf *Workflow) []Diagnostic
+}
+
+// LintConfig configures Lint.
+type LintConfig struct {
+	// Rules defaults to DefaultLintRules.
+	Rules []LintRule
+	// Severity overrides the severity of rules by name, e.g. to enable no-sh for production projects.
+	Severity map[string]Severity
+}
+
+// LintFinding is a violation of a lint rule.
+type LintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Message  string   `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s (%s)", f.File, f.Line, f.Severity, f.Message, f.Rule)
+}
+
+// LintReport holds the findings of Lint in file and line order.
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+	// Suppressed counts the findings hidden by suppression comments.
+	Suppressed int `json:"suppressed"`
+
+	rules []LintRule
+}
+
+// LoadRule names the findings for workflows which can't be loaded.
+const LoadRule = "load"
+
+// Suppression comments hide findings: "# digdag-lint:ignore rule-a,rule-b" at the end of the line of the
+// finding or on the line above it, and "# digdag-lint:ignore-file rule-a" anywhere in the file.
+// Without rule names all rules are suppressed.
+var suppressionPattern = regexp.MustCompile(`#\s*digdag-lint:(ignore-file|ignore)\b\s*([\w,\s-]*)`)
+
+// Lint runs the lint rules over the workflows at the root of the project file system.
+func Lint(fsys fs.FS, cfg LintConfig) (*LintReport, error) {
+	rules := cfg.Rules
+	if rules == nil {
+		rules = DefaultLintRules
+	}
+	known := map[string]bool{}
+	for _, rule := range rules {
+		known[rule.Name] = true
+	}
+	for name, severity := range cfg.Severity {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %s", name)
+		}
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		default:
+			return nil, fmt.Errorf("lint rule %s: unknown severity %q", name, severity)
+		}
+	}
+
+	files, err := fs.Glob(fsys, "*.dig")
+	if err != nil {
+		return nil, err
+	}
+	report := &LintReport{Findings: []LintFinding{}, rules: rules}
+	comments := map[string]*suppressions{}
+	for _, file := range files {
+		wf, err := Load(fsys, file)
+		if err != nil {
+			finding := LintFinding{Rule: LoadRule, Severity: SeverityError, File: file, Message: err.Error()}
+			var digErr *Error
+			if errors.As(err, &digErr) {
+				finding.File, finding.Line, finding.Message = digErr.File, digErr.Line, digErr.Msg
+			}
+			report.Findings = append(report.Findings, finding)
+			continue
+		}
+		for _, rule := range rules {
+			severity := rule.Severity
+			if s, ok := cfg.Severity[rule.Name]; ok {
+				severity = s
+			}
+			if severity == SeverityOff {
+				continue
+			}
+			for _, d := range rule.Check(wf) {
+				s, ok := comments[d.File]
+				if !ok {
+					s = readSuppressions(fsys, d.File)
+					comments[d.File] = s
+				}
+				if s.suppressed(rule.Name, d.Line) {
+					report.Suppressed++
+					continue
+				}
+				report.Findings = append(report.Findings, LintFinding{Rule: rule.Name, Severity: severity, File: d.File, Line: d.Line, Message: d.Message})
+			}
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// suppressions holds the suppression comments of a file. An empty rule name stands for all rules.
+type suppressions struct {
+	lines map[int][]string
+	file  []string
+}
+
+func readSuppressions(fsys fs.FS, name string) *suppressions {
+	s := &suppressions{lines: map[int][]string{}}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return s
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		m := suppressionPattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		rules := []string{""}
+		if names := strings.FieldsFunc(m[2], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }); len(names) > 0 {
+			rules = names
+		}
+		if m[1] == "ignore-file" {
+			s.file = append(s.file, rules...)
+			continue
+		}
+		s.lines[line] = append(s.lines[line], rules...)
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "#") {
+			// a comment on its own line applies to the next line
+			s.lines[line+1] = append(s.lines[line+1], rules...)
+		}
+	}
+	return s
+}
+
+func (s *suppressions) suppressed(rule string, line int) bool {
+	for _, rules := range [][]string{s.file, s.lines[line]} {
+		for _, r := range rules {
+			if r == "" || r == rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasErrors reports whether any finding has error severity.
+func (r *LintReport) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes one finding per line.
+func (r *LintReport) WriteText(w io.Writer) error {
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprintln(w, f.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as a JSON object.
+func (r *LintReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log for code scanning tools.
+func (r *LintReport) WriteSARIF(w io.Writer) error {
+	driver := sarifDriver{Name: "digdag-lint", Rules: []sarifRule{{ID: LoadRule, ShortDescription: sarifMessage{"Workflow can't be loaded"}}}}
+	for _, rule := range r.rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: rule.Name, ShortDescription: sarifMessage{rule.Description}})
+	}
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, f := range r.Findings {
+		level := "note"
+		switch f.Severity {
+		case SeverityError:
+			level = "error"
+		case SeverityWarning:
+			level = "warning"
+		}
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: f.File}}
+		if f.Line > 0 {
+			location.Region = &sarifRegion{StartLine: f.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     level,
+			Message:   sarifMessage{f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Version: "2.1.0", Schema: "https://json.schemastore.org/sarif-2.1.0.json", Runs: []sarifRun{run}})
+}
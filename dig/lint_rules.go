@@ -0,0 +1,80 @@
+package dig
+
+// DefaultLintRules are the built-in lint rules.
+var DefaultLintRules = []LintRule{
+	{
+		Name:        "error-notification",
+		Description: "Scheduled workflows must have an _error task",
+		Severity:    SeverityError,
+		Check:       checkErrorNotification,
+	},
+	{
+		Name:        "timezone",
+		Description: "Workflows must set timezone",
+		Severity:    SeverityWarning,
+		Check:       checkTimezone,
+	},
+	{
+		Name:        "td-database",
+		Description: "td> tasks must declare database",
+		Severity:    SeverityError,
+		Check:       checkTdDatabase,
+	},
+	{
+		Name:        "no-sh",
+		Description: "sh> tasks are not allowed; enable for production projects",
+		Severity:    SeverityOff,
+		Check:       checkNoSh,
+	},
+}
+
+func checkErrorNotification(wf *Workflow) []Diagnostic {
+	if wf.Schedule == nil || wf.Root.Error != nil {
+		return nil
+	}
+	return []Diagnostic{{File: wf.File, Line: wf.Schedule.Line, Message: "scheduled workflow has no _error task"}}
+}
+
+func checkTimezone(wf *Workflow) []Diagnostic {
+	if wf.Timezone != "" {
+		return nil
+	}
+	return []Diagnostic{{File: wf.File, Line: 1, Message: "timezone is not set, UTC is used"}}
+}
+
+func checkTdDatabase(wf *Workflow) []Diagnostic {
+	var diagnostics []Diagnostic
+	// inherited is whether a parent exports the database; params of a task are not inherited
+	var walk func(t *Task, inherited bool)
+	walk = func(t *Task, inherited bool) {
+		inherited = inherited || declaresDatabase(t.Export)
+		if t.Operator == "td" && !inherited && !declaresDatabase(t.Params) {
+			diagnostics = append(diagnostics, Diagnostic{File: t.File, Line: t.Line, Message: "td> task " + t.FullName + " does not declare database"})
+		}
+		for _, child := range t.Children() {
+			walk(child, inherited)
+		}
+	}
+	walk(wf.Root, false)
+	return diagnostics
+}
+
+// declaresDatabase looks for database or td.database, the params td> reads its database from.
+func declaresDatabase(params map[string]interface{}) bool {
+	if params["database"] != nil {
+		return true
+	}
+	td, ok := params["td"].(map[string]interface{})
+	return ok && td["database"] != nil
+}
+
+func checkNoSh(wf *Workflow) []Diagnostic {
+	var diagnostics []Diagnostic
+	wf.Root.Walk(func(t *Task) error {
+		if t.Operator == "sh" {
+			diagnostics = append(diagnostics, Diagnostic{File: t.File, Line: t.Line, Message: "sh> task " + t.FullName + " is not allowed"})
+		}
+		return nil
+	})
+	return diagnostics
+}
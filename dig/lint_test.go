@@ -0,0 +1,150 @@
+package dig
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLint(t *testing.T) {
+	fsys := fstest.MapFS{
+		"daily.dig": {Data: []byte(`schedule:
+  daily>: 07:00:00
+_export:
+  td:
+    database: analytics
++load:
+  td>: load.sql
++notify: # digdag-lint:ignore no-sh
+  sh>: echo done
+`)},
+		"hourly.dig": {Data: []byte(`timezone: UTC
++load:
+  td>: load.sql
+# digdag-lint:ignore td-database
++legacy:
+  td>: legacy.sql
++clean:
+  sh>: rm -rf tmp
+`)},
+		"adhoc.dig":  {Data: []byte("# digdag-lint:ignore-file timezone\n+a:\n  echo>: a\n")},
+		"broken.dig": {Data: []byte("+a: b\n")},
+	}
+
+	report, err := Lint(fsys, LintConfig{Severity: map[string]Severity{"no-sh": SeverityError, "timezone": SeverityInfo}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"broken.dig:1: error: task +broken+a must be a mapping (load)",
+		"daily.dig:1: error: scheduled workflow has no _error task (error-notification)",
+		"daily.dig:1: info: timezone is not set, UTC is used (timezone)",
+		"hourly.dig:2: error: td> task +hourly+load does not declare database (td-database)",
+		"hourly.dig:7: error: sh> task +hourly+clean is not allowed (no-sh)",
+	}
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSpace(text.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("findings wrong. got=\n%s", text.String())
+	}
+	if report.Suppressed != 3 || !report.HasErrors() {
+		t.Fatalf("report wrong. got suppressed=%d", report.Suppressed)
+	}
+
+	var sarif struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	var buf bytes.Buffer
+	if err := report.WriteSARIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &sarif); err != nil {
+		t.Fatal(err)
+	}
+	results := sarif.Runs[0].Results
+	if sarif.Version != "2.1.0" || len(results) != 5 || results[2].Level != "note" || results[3].RuleID != "td-database" ||
+		results[3].Locations[0].PhysicalLocation.ArtifactLocation.URI != "hourly.dig" || results[3].Locations[0].PhysicalLocation.Region.StartLine != 2 {
+		t.Fatalf("sarif wrong. got=%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded LintReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Findings) != 5 || decoded.Suppressed != 3 {
+		t.Fatalf("json wrong. got=%s, %v", buf.String(), err)
+	}
+
+	if _, err := Lint(fsys, LintConfig{Severity: map[string]Severity{"no-such-rule": SeverityError}}); err == nil {
+		t.Fatal("expected an error for an unknown rule")
+	}
+}
+
+func TestLintCustomRule(t *testing.T) {
+	fsys := fstest.MapFS{"a.dig": {Data: []byte("timezone: UTC\n+a:\n  echo>: a\n")}}
+	rule := LintRule{
+		Name:     "no-echo",
+		Severity: SeverityWarning,
+		Check: func(wf *Workflow) []Diagnostic {
+			var diagnostics []Diagnostic
+			wf.Root.Walk(func(t *Task) error {
+				if t.Operator == "echo" {
+					diagnostics = append(diagnostics, Diagnostic{File: t.File, Line: t.Line, Message: "echo"})
+				}
+				return nil
+			})
+			return diagnostics
+		},
+	}
+	report, err := Lint(fsys, LintConfig{Rules: []LintRule{rule}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0] != (LintFinding{Rule: "no-echo", Severity: SeverityWarning, File: "a.dig", Line: 2, Message: "echo"}) {
+		t.Fatalf("findings wrong. got=%v", report.Findings)
+	}
+}
+
+func TestCheckTdDatabase(t *testing.T) {
+	wf, err := Parse("etl.dig", []byte(`+exported:
+  _export:
+    database: analytics
+  +load:
+    td>: load.sql
++group_param:
+  database: analytics
+  +load:
+    td>: load.sql
++own_param:
+  td>: own.sql
+  database: analytics
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := checkTdDatabase(wf)
+	if len(diagnostics) != 1 || diagnostics[0].Line != 8 {
+		t.Fatalf("a database param of a group must not be inherited. got=%+v", diagnostics)
+	}
+}
@@ -0,0 +1,108 @@
+package dig
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxIncludeDepth bounds nested !include directives.
+const maxIncludeDepth = 16
+
+// Load parses the .dig file name of a project file system such as ProjectArchive.FS or os.DirFS and
+// resolves its !include directives. Included paths are relative to the including file. The keys of an
+// included file replace the directive; included tasks keep their own file and lines.
+func Load(fsys fs.FS, name string) (*Workflow, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseNode(name, data)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{origin: map[*yaml.Node]string{}}
+	if root != nil {
+		if err := b.expand(fsys, root, name, []string{name}); err != nil {
+			return nil, err
+		}
+	}
+	return b.workflow(strings.TrimSuffix(path.Base(name), ".dig"), name, root)
+}
+
+// expand splices the included files into the mappings of node.
+func (b *builder) expand(fsys fs.FS, node *yaml.Node, file string, stack []string) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := b.expand(fsys, item, file, stack); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyFile := b.fileOf(key, file)
+			if !isInclude(key) {
+				if err := b.expand(fsys, value, keyFile, stack); err != nil {
+					return err
+				}
+				content = append(content, key, value)
+				continue
+			}
+			included, err := b.include(fsys, key, value, keyFile, stack)
+			if err != nil {
+				return err
+			}
+			content = append(content, included...)
+		}
+		node.Content = content
+	}
+	return nil
+}
+
+// include reads, parses and expands the file of an !include directive and returns its keys and values.
+func (b *builder) include(fsys fs.FS, key, value *yaml.Node, file string, stack []string) ([]*yaml.Node, error) {
+	fail := func(format string, args ...interface{}) error {
+		return &Error{File: file, Line: key.Line, Msg: fmt.Sprintf(format, args...)}
+	}
+	if value.Kind != yaml.ScalarNode || value.Value == "" {
+		return nil, fail("!include needs a file name")
+	}
+	target := path.Join(path.Dir(file), value.Value)
+	if !fs.ValidPath(target) {
+		return nil, fail("!include %s is outside of the project", value.Value)
+	}
+	for _, f := range stack {
+		if f == target {
+			return nil, fail("!include %s is circular", value.Value)
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, fail("!include %s is nested too deeply", value.Value)
+	}
+	data, err := fs.ReadFile(fsys, target)
+	if err != nil {
+		return nil, fail("!include %s: %s", value.Value, err)
+	}
+	root, err := parseNode(target, data)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, nil
+	}
+	if err := b.expand(fsys, root, target, append(stack, target)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		if _, ok := b.origin[root.Content[i]]; !ok {
+			b.origin[root.Content[i]] = target
+		}
+	}
+	return root.Content, nil
+}
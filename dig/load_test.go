@@ -0,0 +1,45 @@
+package dig
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"daily.dig":          {Data: []byte("_export:\n  !include : 'config/params.yml'\n  b: 2\n+a:\n  echo>: a\n!include : 'tasks/more.dig'\n")},
+		"config/params.yml":  {Data: []byte("a: 1\n")},
+		"tasks/more.dig":     {Data: []byte("+b:\n  !include : 'nested.dig'\n")},
+		"tasks/nested.dig":   {Data: []byte("# nested\n+c:\n  td>: c.sql\n")},
+		"circular.dig":       {Data: []byte("!include : 'circular.dig'\n")},
+		"missing.dig":        {Data: []byte("+a:\n  echo>: a\n+b:\n  !include : 'nope.dig'\n")},
+		"outside/escape.dig": {Data: []byte("!include : '../../x.dig'\n")},
+	}
+	wf, err := Load(fsys, "daily.dig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Root.Export["a"] != 1 || wf.Root.Export["b"] != 2 || len(wf.Root.Includes) != 0 {
+		t.Fatalf("export wrong. got=%v", wf.Root.Export)
+	}
+	if len(wf.Root.Tasks) != 2 {
+		t.Fatalf("tasks wrong. got=%+v", wf.Root.Tasks)
+	}
+	b := wf.Root.Tasks[1]
+	if b.FullName != "+daily+b" || b.File != "tasks/more.dig" || b.Line != 1 {
+		t.Fatalf("included task wrong. got=%+v", b)
+	}
+	c := b.Tasks[0]
+	if c.FullName != "+daily+b+c" || c.File != "tasks/nested.dig" || c.Line != 2 || c.Command != "c.sql" {
+		t.Fatalf("nested included task wrong. got=%+v", c)
+	}
+
+	for name, line := range map[string]int{"circular.dig": 1, "missing.dig": 4, "outside/escape.dig": 1} {
+		_, err := Load(fsys, name)
+		var digErr *Error
+		if !errors.As(err, &digErr) || digErr.File != name || digErr.Line != line {
+			t.Errorf("%s: error wrong. got=%v", name, err)
+		}
+	}
+}
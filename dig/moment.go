@@ -0,0 +1,382 @@
+package dig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMomentFormat is what format() without arguments uses.
+const defaultMomentFormat = "YYYY-MM-DDTHH:mm:ssZ"
+
+// Moment is the subset of moment.js which workflows use to compute dates, e.g.
+// moment(session_time).add(-1, 'days').format('YYYYMMDD').
+type Moment struct {
+	t time.Time
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+// momentLayouts are the formats moment(string) parses.
+var momentLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func newMoment(args ...interface{}) (interface{}, error) {
+	if len(args) == 0 || args[0] == nil {
+		return &Moment{now().UTC()}, nil
+	}
+	switch v := normalize(args[0]).(type) {
+	case *Moment:
+		return &Moment{v.t}, nil
+	case float64:
+		return &Moment{time.UnixMilli(int64(v)).UTC()}, nil
+	case string:
+		if len(args) > 1 {
+			layout, err := goLayout(toString(args[1]))
+			if err != nil {
+				return nil, err
+			}
+			t, err := time.Parse(layout, v)
+			if err != nil {
+				return nil, fmt.Errorf("moment: %w", err)
+			}
+			return &Moment{t}, nil
+		}
+		for _, layout := range momentLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return &Moment{t}, nil
+			}
+		}
+		return nil, fmt.Errorf("moment: cannot parse %q", v)
+	}
+	return nil, fmt.Errorf("moment: unsupported argument %v", args[0])
+}
+
+func (m *Moment) call(name string, args []interface{}) (interface{}, error) {
+	switch name {
+	case "format":
+		layout := defaultMomentFormat
+		if len(args) > 0 {
+			layout = toString(args[0])
+		}
+		return m.format(layout)
+	case "add", "subtract":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("moment.%s needs an amount and a unit", name)
+		}
+		n := int(toNumber(args[0]))
+		if name == "subtract" {
+			n = -n
+		}
+		t, err := addUnit(m.t, n, toString(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		m.t = t
+		return m, nil
+	case "startOf", "endOf":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("moment.%s needs a unit", name)
+		}
+		t, err := startOf(m.t, toString(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		if name == "endOf" {
+			t, _ = addUnit(t, 1, toString(args[0]))
+			t = t.Add(-time.Millisecond)
+		}
+		m.t = t
+		return m, nil
+	case "tz":
+		if len(args) < 1 {
+			return m.t.Location().String(), nil
+		}
+		loc, err := time.LoadLocation(toString(args[0]))
+		if err != nil {
+			return nil, fmt.Errorf("moment.tz: %w", err)
+		}
+		m.t = m.t.In(loc)
+		return m, nil
+	case "utc":
+		m.t = m.t.UTC()
+		return m, nil
+	case "clone":
+		return &Moment{m.t}, nil
+	case "unix":
+		return float64(m.t.Unix()), nil
+	case "valueOf":
+		return float64(m.t.UnixMilli()), nil
+	case "toISOString":
+		return m.t.UTC().Format("2006-01-02T15:04:05.000Z"), nil
+	case "year":
+		return float64(m.t.Year()), nil
+	case "month":
+		return float64(m.t.Month() - 1), nil
+	case "date":
+		return float64(m.t.Day()), nil
+	case "day":
+		return float64(m.t.Weekday()), nil
+	case "hour":
+		return float64(m.t.Hour()), nil
+	case "minute":
+		return float64(m.t.Minute()), nil
+	case "second":
+		return float64(m.t.Second()), nil
+	case "isBefore", "isAfter", "isSame":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("moment.%s needs a moment", name)
+		}
+		other, err := newMoment(args[0])
+		if err != nil {
+			return nil, err
+		}
+		o := other.(*Moment).t
+		switch name {
+		case "isBefore":
+			return m.t.Before(o), nil
+		case "isAfter":
+			return m.t.After(o), nil
+		}
+		return m.t.Equal(o), nil
+	case "diff":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("moment.diff needs a moment")
+		}
+		other, err := newMoment(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d := m.t.Sub(other.(*Moment).t)
+		unit := "milliseconds"
+		if len(args) > 1 {
+			unit = toString(args[1])
+		}
+		switch normalizeUnit(unit) {
+		case "day":
+			return float64(int64(d / (24 * time.Hour))), nil
+		case "hour":
+			return float64(int64(d / time.Hour)), nil
+		case "minute":
+			return float64(int64(d / time.Minute)), nil
+		case "second":
+			return float64(int64(d / time.Second)), nil
+		case "millisecond":
+			return float64(d.Milliseconds()), nil
+		}
+		return nil, fmt.Errorf("moment.diff: unsupported unit %q", unit)
+	}
+	return nil, fmt.Errorf("moment has no method %s", name)
+}
+
+func normalizeUnit(unit string) string {
+	switch unit {
+	case "y", "year", "years":
+		return "year"
+	case "M", "month", "months":
+		return "month"
+	case "w", "week", "weeks":
+		return "week"
+	case "d", "day", "days":
+		return "day"
+	case "h", "hour", "hours":
+		return "hour"
+	case "m", "minute", "minutes":
+		return "minute"
+	case "s", "second", "seconds":
+		return "second"
+	case "ms", "millisecond", "milliseconds":
+		return "millisecond"
+	}
+	return unit
+}
+
+func addUnit(t time.Time, n int, unit string) (time.Time, error) {
+	switch normalizeUnit(unit) {
+	case "year":
+		return t.AddDate(n, 0, 0), nil
+	case "month":
+		// moment clamps to the end of shorter months
+		first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, n, 0)
+		last := first.AddDate(0, 1, -1).Day()
+		day := t.Day()
+		if day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1), nil
+	case "week":
+		return t.AddDate(0, 0, 7*n), nil
+	case "day":
+		return t.AddDate(0, 0, n), nil
+	case "hour":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "minute":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case "second":
+		return t.Add(time.Duration(n) * time.Second), nil
+	case "millisecond":
+		return t.Add(time.Duration(n) * time.Millisecond), nil
+	}
+	return t, fmt.Errorf("moment: unsupported unit %q", unit)
+}
+
+func startOf(t time.Time, unit string) (time.Time, error) {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	switch normalizeUnit(unit) {
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), nil
+	case "week":
+		return time.Date(y, mo, d-int(t.Weekday()), 0, 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), nil
+	case "hour":
+		return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc), nil
+	case "minute":
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case "second":
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+	}
+	return t, fmt.Errorf("moment: unsupported unit %q", unit)
+}
+
+// momentTokens are the format tokens of moment.js, longest first, with their Go layouts.
+// Tokens without a Go layout are formatted by formatToken.
+var momentTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"}, {"GGGG", ""}, {"GG", ""},
+	{"Q", ""},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"WW", ""}, {"W", ""},
+	{"DDDD", ""}, {"DDD", ""}, {"Do", ""}, {"DD", "02"}, {"D", "2"},
+	{"dddd", "Monday"}, {"ddd", "Mon"}, {"d", ""}, {"E", ""},
+	{"HH", "15"}, {"H", ""}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"m", "4"}, {"ss", "05"}, {"s", "5"},
+	{"SSS", ".000"},
+	{"A", "PM"}, {"a", "pm"},
+	{"ZZ", "-0700"}, {"Z", "-07:00"},
+	{"X", ""}, {"x", ""},
+}
+
+// unsupportedMomentTokens are moment.js tokens which depend on the locale. They are rejected
+// rather than printed literally.
+var unsupportedMomentTokens = []string{"gggg", "gg", "wo", "ww", "w", "Wo", "e"}
+
+func (m *Moment) format(layout string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(layout); {
+		if layout[i] == '[' {
+			end := strings.IndexByte(layout[i:], ']')
+			if end > 0 {
+				b.WriteString(layout[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		for _, tok := range unsupportedMomentTokens {
+			if strings.HasPrefix(layout[i:], tok) {
+				return "", fmt.Errorf("moment: token %s is not supported", tok)
+			}
+		}
+		matched := false
+		for _, tok := range momentTokens {
+			if !strings.HasPrefix(layout[i:], tok.token) {
+				continue
+			}
+			b.WriteString(formatToken(m.t, tok.token, tok.layout))
+			i += len(tok.token)
+			matched = true
+			break
+		}
+		if !matched {
+			b.WriteByte(layout[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+func formatToken(t time.Time, token, layout string) string {
+	switch token {
+	case "SSS":
+		return t.Format(layout)[1:]
+	case "GGGG", "GG":
+		year, _ := t.ISOWeek()
+		if token == "GG" {
+			return fmt.Sprintf("%02d", year%100)
+		}
+		return fmt.Sprintf("%04d", year)
+	case "Q":
+		return strconv.Itoa((int(t.Month())-1)/3 + 1)
+	case "WW":
+		_, week := t.ISOWeek()
+		return fmt.Sprintf("%02d", week)
+	case "W":
+		_, week := t.ISOWeek()
+		return strconv.Itoa(week)
+	case "DDDD":
+		return fmt.Sprintf("%03d", t.YearDay())
+	case "DDD":
+		return strconv.Itoa(t.YearDay())
+	case "Do":
+		return ordinal(t.Day())
+	case "d":
+		return strconv.Itoa(int(t.Weekday()))
+	case "E":
+		return strconv.Itoa((int(t.Weekday())+6)%7 + 1)
+	case "H":
+		return strconv.Itoa(t.Hour())
+	case "X":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "x":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(layout)
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// goLayout converts a moment.js format for parsing. Only tokens with a Go layout are supported.
+func goLayout(format string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		for _, tok := range unsupportedMomentTokens {
+			if strings.HasPrefix(format[i:], tok) {
+				return "", fmt.Errorf("moment: token %s is not supported for parsing", tok)
+			}
+		}
+		matched := false
+		for _, tok := range momentTokens {
+			if !strings.HasPrefix(format[i:], tok.token) {
+				continue
+			}
+			if tok.layout == "" {
+				return "", fmt.Errorf("moment: token %s is not supported for parsing", tok.token)
+			}
+			b.WriteString(tok.layout)
+			i += len(tok.token)
+			matched = true
+			break
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,396 @@
+package dig
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const includeTag = "!include"
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// Parse parses the .dig file at the slash-separated path file. !include directives are not resolved
+// but listed in the Includes of the tasks; use Load to resolve them.
+func Parse(file string, data []byte) (*Workflow, error) {
+	root, err := parseNode(file, data)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{}
+	return b.workflow(strings.TrimSuffix(path.Base(file), ".dig"), file, root)
+}
+
+// ParseConfig parses the config of a workflow as returned by the server, e.g. in DetailedWorkflow.
+// JSON is a subset of YAML, so the order of the tasks is preserved.
+func ParseConfig(name string, config []byte) (*Workflow, error) {
+	root, err := parseNode(name, config)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{}
+	return b.workflow(name, "", root)
+}
+
+// parseNode parses a YAML document and returns its top level mapping, or nil when it's empty.
+func parseNode(file string, data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		e := &Error{File: file, Msg: err.Error()}
+		if m := yamlErrorLine.FindStringSubmatch(e.Msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Msg = strings.TrimPrefix(e.Msg, m[0])
+		}
+		return nil, e
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+		return nil, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, &Error{File: file, Line: root.Line, Msg: "workflow definition must be a mapping"}
+	}
+	return root, nil
+}
+
+func isInclude(key *yaml.Node) bool {
+	return key.Tag == includeTag
+}
+
+// builder turns mapping nodes into tasks.
+type builder struct {
+	// origin holds the file of keys spliced in by !include.
+	origin map[*yaml.Node]string
+}
+
+func (b *builder) fileOf(key *yaml.Node, file string) string {
+	if f, ok := b.origin[key]; ok {
+		return f
+	}
+	return file
+}
+
+func (b *builder) workflow(name, file string, root *yaml.Node) (*Workflow, error) {
+	wf := &Workflow{Name: name, File: file}
+	if root == nil {
+		root = &yaml.Node{Kind: yaml.MappingNode, Line: 1}
+	}
+	task, err := b.task("", "+"+name, file, root.Line, root, true)
+	if err != nil {
+		return nil, err
+	}
+	wf.Root = task
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		keyFile := b.fileOf(key, file)
+		switch key.Value {
+		case "timezone":
+			if value.Kind != yaml.ScalarNode {
+				return nil, &Error{File: keyFile, Line: key.Line, Msg: "timezone must be a string"}
+			}
+			wf.Timezone = value.Value
+		case "schedule":
+			schedule, err := parseSchedule(keyFile, key, value)
+			if err != nil {
+				return nil, err
+			}
+			wf.Schedule = schedule
+		}
+	}
+	return wf, nil
+}
+
+func (b *builder) task(name, fullName, file string, line int, node *yaml.Node, root bool) (*Task, error) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, &Error{File: file, Line: line, Msg: fmt.Sprintf("task %s must be a mapping", fullName)}
+	}
+	t := &Task{Name: name, FullName: fullName, File: file, Line: line, Node: node, children: []*Task{}}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		keyFile := b.fileOf(key, file)
+		if isInclude(key) {
+			t.Includes = append(t.Includes, Include{Path: value.Value, Line: key.Line})
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return &Error{File: keyFile, Line: key.Line, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		var child **Task
+		childName := ""
+		switch k := key.Value; {
+		case strings.HasPrefix(k, "+"):
+			sub, err := b.task(k, fullName+k, keyFile, key.Line, value, false)
+			if err != nil {
+				return nil, err
+			}
+			sub.parent = t
+			t.Tasks = append(t.Tasks, sub)
+			t.children = append(t.children, sub)
+			continue
+		case strings.HasSuffix(k, ">"):
+			if t.Operator != "" {
+				return nil, fail("task %s has more than one operator", fullName)
+			}
+			t.Operator = strings.TrimSuffix(k, ">")
+			v, err := decodeValue(value)
+			if err != nil {
+				return nil, fail("%s: %s", k, err)
+			}
+			t.Command = v
+			continue
+		case k == "_export":
+			export, err := decodeValue(value)
+			if err != nil {
+				return nil, fail("_export: %s", err)
+			}
+			m, ok := export.(map[string]interface{})
+			if export != nil && !ok {
+				return nil, fail("_export must be a mapping")
+			}
+			t.Includes = append(t.Includes, includesOf(value)...)
+			t.Export = m
+			continue
+		case k == "_retry":
+			retry, err := parseRetry(value)
+			if err != nil {
+				return nil, fail("invalid _retry: %s", err)
+			}
+			retry.Line = key.Line
+			t.Retry = retry
+			continue
+		case k == "_parallel":
+			parallel, err := parseParallel(value)
+			if err != nil {
+				return nil, fail("invalid _parallel: %s", err)
+			}
+			t.Parallel = parallel
+			continue
+		case k == "_background":
+			background, err := strconv.ParseBool(value.Value)
+			if value.Kind != yaml.ScalarNode || err != nil {
+				return nil, fail("_background must be true or false")
+			}
+			t.Background = background
+			continue
+		case k == "_error":
+			child, childName = &t.Error, "^error"
+		case k == "_check":
+			child, childName = &t.Check, "^check"
+		case k == "_do":
+			child, childName = &t.Do, "^sub"
+		case k == "_else_do":
+			child, childName = &t.ElseDo, "^sub"
+		case root && (k == "timezone" || k == "schedule"):
+			continue
+		default:
+			v, err := decodeValue(value)
+			if err != nil {
+				return nil, fail("%s: %s", k, err)
+			}
+			if t.Params == nil {
+				t.Params = map[string]interface{}{}
+			}
+			t.Params[k] = v
+			continue
+		}
+
+		sub, err := b.task(childName, fullName+childName, keyFile, key.Line, value, false)
+		if err != nil {
+			return nil, err
+		}
+		sub.parent = t
+		*child = sub
+		t.children = append(t.children, sub)
+	}
+	return t, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// includesOf lists the !include directives of a mapping which isn't a task, such as _export.
+func includesOf(node *yaml.Node) []Include {
+	var includes []Include
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if isInclude(node.Content[i]) {
+			includes = append(includes, Include{Path: node.Content[i+1].Value, Line: node.Content[i].Line})
+		}
+	}
+	return includes
+}
+
+// decodeValue decodes a node into plain values like encoding/json does, skipping !include keys.
+func decodeValue(node *yaml.Node) (interface{}, error) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if isInclude(key) {
+				continue
+			}
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be strings", key.Line)
+			}
+			v, err := decodeValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := decodeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func isExpr(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+func parseRetry(node *yaml.Node) (*Retry, error) {
+	retry := &Retry{}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if isExpr(node.Value) {
+			retry.Expr = node.Value
+			return retry, nil
+		}
+		limit, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", node.Value)
+		}
+		retry.Limit = limit
+		return retry, nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, resolveAlias(node.Content[i+1])
+			if value.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s must be a scalar", key)
+			}
+			switch key {
+			case "interval_type":
+				retry.IntervalType = value.Value
+				continue
+			case "limit", "interval", "max_interval":
+			default:
+				return nil, fmt.Errorf("unknown key %s", key)
+			}
+			if isExpr(value.Value) {
+				retry.Expr = value.Value
+				continue
+			}
+			n, err := strconv.Atoi(value.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", key, value.Value)
+			}
+			switch key {
+			case "limit":
+				retry.Limit = n
+			case "interval":
+				retry.Interval = n
+			case "max_interval":
+				retry.MaxInterval = n
+			}
+		}
+		return retry, nil
+	}
+	return nil, fmt.Errorf("must be a number or a mapping")
+}
+
+func parseParallel(node *yaml.Node) (Parallel, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if isExpr(node.Value) {
+			return Parallel{Expr: node.Value}, nil
+		}
+		enabled, err := strconv.ParseBool(node.Value)
+		if err != nil {
+			return Parallel{}, fmt.Errorf("%q is not a boolean", node.Value)
+		}
+		return Parallel{Enabled: enabled}, nil
+	case yaml.MappingNode:
+		parallel := Parallel{Enabled: true}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, resolveAlias(node.Content[i+1])
+			if key != "limit" {
+				return Parallel{}, fmt.Errorf("unknown key %s", key)
+			}
+			if isExpr(value.Value) {
+				parallel.Expr = value.Value
+				continue
+			}
+			limit, err := strconv.Atoi(value.Value)
+			if err != nil {
+				return Parallel{}, fmt.Errorf("limit: %q is not a number", value.Value)
+			}
+			parallel.Limit = limit
+		}
+		return parallel, nil
+	}
+	return Parallel{}, fmt.Errorf("must be a boolean or a mapping")
+}
+
+func parseSchedule(file string, key, node *yaml.Node) (*Schedule, error) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, &Error{File: file, Line: key.Line, Msg: "schedule must be a mapping"}
+	}
+	schedule := &Schedule{Line: key.Line}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, value := node.Content[i], resolveAlias(node.Content[i+1])
+		if strings.HasSuffix(k.Value, ">") {
+			if schedule.Kind != "" {
+				return nil, &Error{File: file, Line: k.Line, Msg: "schedule has more than one operator"}
+			}
+			if value.Kind != yaml.ScalarNode {
+				return nil, &Error{File: file, Line: k.Line, Msg: fmt.Sprintf("%s must be a scalar", k.Value)}
+			}
+			schedule.Kind = strings.TrimSuffix(k.Value, ">")
+			schedule.Value = value.Value
+			continue
+		}
+		v, err := decodeValue(value)
+		if err != nil {
+			return nil, &Error{File: file, Line: k.Line, Msg: fmt.Sprintf("%s: %s", k.Value, err)}
+		}
+		if schedule.Options == nil {
+			schedule.Options = map[string]interface{}{}
+		}
+		schedule.Options[k.Value] = v
+	}
+	if schedule.Kind == "" {
+		return nil, &Error{File: file, Line: key.Line, Msg: "schedule has no operator"}
+	}
+	return schedule, nil
+}
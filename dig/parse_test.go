@@ -0,0 +1,162 @@
+package dig
+
+import (
+	"errors"
+	"testing"
+)
+
+const dailyDig = `timezone: Asia/Tokyo
+
+schedule:
+  daily>: 07:00:00
+  skip_delayed_by: 1h
+
+_export:
+  td:
+    database: analytics
+  !include : 'config/params.yml'
+
+_error:
+  +notify:
+    sh>: scripts/notify.sh
+
++load:
+  _retry:
+    limit: 3
+    interval: 60
+    interval_type: exponential
+  _parallel: true
+  +orders:
+    td>: queries/orders.sql
+    insert_into: orders_daily
+  +users:
+    td>: queries/users.sql
+    create_table: users_daily
+
++per_region:
+  for_each>:
+    region: [us, eu]
+  _do:
+    call>: region.dig
+
++wait:
+  require>: upstream
+  project_name: shared
+`
+
+func TestParse(t *testing.T) {
+	wf, err := Parse("daily.dig", []byte(dailyDig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Name != "daily" || wf.Timezone != "Asia/Tokyo" {
+		t.Fatalf("workflow wrong. got=%+v", wf)
+	}
+	if wf.Schedule == nil || wf.Schedule.Kind != "daily" || wf.Schedule.Value != "07:00:00" || wf.Schedule.Line != 3 || wf.Schedule.Options["skip_delayed_by"] != "1h" {
+		t.Fatalf("schedule wrong. got=%+v", wf.Schedule)
+	}
+	root := wf.Root
+	if root.FullName != "+daily" || len(root.Includes) != 1 || root.Includes[0].Path != "config/params.yml" || root.Includes[0].Line != 10 {
+		t.Fatalf("root wrong. got=%+v", root)
+	}
+	if td, ok := root.Export["td"].(map[string]interface{}); !ok || td["database"] != "analytics" {
+		t.Fatalf("export wrong. got=%v", root.Export)
+	}
+	if root.Error == nil || root.Error.FullName != "+daily^error" || root.Error.Tasks[0].Operator != "sh" {
+		t.Fatalf("error task wrong. got=%+v", root.Error)
+	}
+
+	var names []string
+	root.Walk(func(task *Task) error {
+		names = append(names, task.FullName)
+		return nil
+	})
+	want := []string{"+daily", "+daily^error", "+daily^error+notify", "+daily+load", "+daily+load+orders", "+daily+load+users",
+		"+daily+per_region", "+daily+per_region^sub", "+daily+wait"}
+	if len(names) != len(want) {
+		t.Fatalf("walk wrong. got=%v", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("walk wrong. got=%v", names)
+		}
+	}
+
+	load := root.Tasks[0]
+	if !load.IsGroup() || !load.Parallel.Enabled || load.Retry == nil || *load.Retry != (Retry{Limit: 3, Interval: 60, IntervalType: "exponential", Line: 17}) {
+		t.Fatalf("load wrong. got=%+v, retry=%+v", load, load.Retry)
+	}
+	orders := load.Tasks[0]
+	if orders.Operator != "td" || orders.Command != "queries/orders.sql" || orders.Params["insert_into"] != "orders_daily" || orders.Line != 22 {
+		t.Fatalf("orders wrong. got=%+v", orders)
+	}
+	if orders.KeyLine("insert_into") != 24 {
+		t.Fatalf("key line wrong. got=%d", orders.KeyLine("insert_into"))
+	}
+	loop := root.Tasks[1]
+	if loop.Operator != "for_each" || loop.Do == nil || loop.Do.Operator != "call" || loop.Do.Command != "region.dig" {
+		t.Fatalf("for_each wrong. got=%+v", loop)
+	}
+	wait := root.Tasks[2]
+	if wait.Operator != "require" || wait.Command != "upstream" || wait.Params["project_name"] != "shared" {
+		t.Fatalf("require wrong. got=%+v", wait)
+	}
+}
+
+func TestParseShortDirectives(t *testing.T) {
+	wf, err := Parse("a.dig", []byte("_retry: 2\n_parallel:\n  limit: 4\n+a:\n  echo>: a\n  _background: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Root.Retry.Limit != 2 || wf.Root.Parallel != (Parallel{Enabled: true, Limit: 4}) || !wf.Root.Tasks[0].Background {
+		t.Fatalf("directives wrong. got=%+v", wf.Root)
+	}
+	wf, err = Parse("b.dig", []byte("_retry: ${retries}\n"))
+	if err != nil || wf.Root.Retry.Expr != "${retries}" {
+		t.Fatalf("expression wrong. got=%+v, %v", wf, err)
+	}
+	wf, err = Parse("empty.dig", nil)
+	if err != nil || wf.Root == nil || len(wf.Root.Tasks) != 0 {
+		t.Fatalf("empty workflow wrong. got=%+v, %v", wf, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		line int
+	}{
+		{"yaml syntax", "+a:\n\techo>: a\n", 2},
+		{"not a mapping", "- a\n", 1},
+		{"task not a mapping", "+a: b\n", 1},
+		{"two operators", "+a:\n  echo>: a\n  sh>: b\n", 3},
+		{"bad retry", "+a:\n  _retry: often\n", 2},
+		{"bad retry key", "_retry:\n  limits: 3\n", 1},
+		{"bad parallel", "_parallel: sometimes\n", 1},
+		{"schedule without operator", "schedule:\n  start: 2024-01-01\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("a.dig", []byte(tt.body))
+			var digErr *Error
+			if !errors.As(err, &digErr) {
+				t.Fatalf("expected an *Error. got=%v", err)
+			}
+			if digErr.File != "a.dig" || digErr.Line != tt.line {
+				t.Fatalf("error position wrong. got=%v", digErr)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config := `{"schedule":{"cron>":"0 * * * *"},"+b":{"echo>":"b"},"+a":{"td>":"q.sql","database":"db"}}`
+	wf, err := ParseConfig("hourly", []byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Schedule.Kind != "cron" || len(wf.Root.Tasks) != 2 || wf.Root.Tasks[0].Name != "+b" || wf.Root.Tasks[1].Params["database"] != "db" {
+		t.Fatalf("config wrong. got=%+v", wf.Root)
+	}
+}
@@ -0,0 +1,299 @@
+package dig
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionOptions describes the session which built-in variables are computed for.
+type SessionOptions struct {
+	SessionTime time.Time
+	// Timezone is the timezone of the workflow and defaults to UTC.
+	Timezone string
+	// Schedule gives the last_session_* and next_session_* variables, which are omitted without it.
+	Schedule *Schedule
+	// The other variables are set when not empty.
+	SessionID   string
+	AttemptID   string
+	SessionUUID string
+	ProjectID   string
+	TaskName    string
+}
+
+// SessionVars returns the built-in variables of Digdag for a session, such as session_time,
+// session_date, session_date_compact, last_session_date and next_session_time.
+func SessionVars(opts SessionOptions) (map[string]interface{}, error) {
+	tz := opts.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]interface{}{"timezone": tz}
+	sessionTime := opts.SessionTime.In(loc)
+	addTimeVars(vars, "session", sessionTime)
+	if opts.Schedule != nil {
+		last, err := stepSchedule(opts.Schedule, sessionTime, -1)
+		if err != nil {
+			return nil, err
+		}
+		next, err := stepSchedule(opts.Schedule, sessionTime, 1)
+		if err != nil {
+			return nil, err
+		}
+		addTimeVars(vars, "last_session", last)
+		addTimeVars(vars, "next_session", next)
+	}
+	for name, value := range map[string]string{
+		"session_id":   opts.SessionID,
+		"attempt_id":   opts.AttemptID,
+		"session_uuid": opts.SessionUUID,
+		"project_id":   opts.ProjectID,
+		"task_name":    opts.TaskName,
+	} {
+		if value != "" {
+			vars[name] = value
+		}
+	}
+	return vars, nil
+}
+
+func addTimeVars(vars map[string]interface{}, prefix string, t time.Time) {
+	vars[prefix+"_time"] = t.Format("2006-01-02T15:04:05-07:00")
+	vars[prefix+"_date"] = t.Format("2006-01-02")
+	vars[prefix+"_date_compact"] = t.Format("20060102")
+	vars[prefix+"_local_time"] = t.Format("2006-01-02 15:04:05")
+	vars[prefix+"_tz_offset"] = t.Format("-0700")
+	vars[prefix+"_unixtime"] = t.Unix()
+}
+
+// stepSchedule returns the session time before (step -1) or after (step 1) t on the schedule.
+func stepSchedule(s *Schedule, t time.Time, step int) (time.Time, error) {
+	switch s.Kind {
+	case "hourly":
+		return t.Add(time.Duration(step) * time.Hour), nil
+	case "daily":
+		return t.AddDate(0, 0, step), nil
+	case "weekly":
+		return t.AddDate(0, 0, 7*step), nil
+	case "monthly":
+		return t.AddDate(0, step, 0), nil
+	case "minutes_interval":
+		n, err := strconv.Atoi(strings.TrimSpace(s.Value))
+		if err != nil || n <= 0 {
+			return t, fmt.Errorf("invalid minutes_interval> %q", s.Value)
+		}
+		return t.Add(time.Duration(step*n) * time.Minute), nil
+	case "cron":
+		c, err := parseCron(s.Value)
+		if err != nil {
+			return t, err
+		}
+		return c.step(t, step)
+	}
+	return t, fmt.Errorf("unknown schedule %s>", s.Kind)
+}
+
+// cronSchedule holds the allowed values of the five cron fields.
+type cronSchedule struct {
+	fields [5]map[int]bool
+}
+
+var cronNames = [5]map[string]int{
+	nil, nil, nil,
+	{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12},
+	{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6},
+}
+
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron> needs 5 fields, got %q", spec)
+	}
+	c := &cronSchedule{}
+	for i, field := range fields {
+		allowed := map[int]bool{}
+		for _, part := range strings.Split(field, ",") {
+			rangePart, stepPart, hasStep := strings.Cut(part, "/")
+			step := 1
+			if hasStep {
+				n, err := strconv.Atoi(stepPart)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("cron> field %q is invalid", field)
+				}
+				step = n
+			}
+			lo, hi := cronRanges[i][0], cronRanges[i][1]
+			if rangePart != "*" && rangePart != "?" {
+				from, to, isRange := strings.Cut(rangePart, "-")
+				var err error
+				if lo, err = cronValue(i, from); err != nil {
+					return nil, err
+				}
+				hi = lo
+				if isRange {
+					if hi, err = cronValue(i, to); err != nil {
+						return nil, err
+					}
+				} else if hasStep {
+					hi = cronRanges[i][1]
+				}
+			}
+			for v := lo; v <= hi; v += step {
+				allowed[v] = true
+			}
+		}
+		if i == 4 && allowed[7] {
+			allowed[0] = true
+		}
+		c.fields[i] = allowed
+	}
+	return c, nil
+}
+
+func cronValue(field int, s string) (int, error) {
+	if v, ok := cronNames[field][strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < cronRanges[field][0] || v > cronRanges[field][1] {
+		return 0, fmt.Errorf("cron> value %q is invalid", s)
+	}
+	return v, nil
+}
+
+func (c *cronSchedule) matches(t time.Time) bool {
+	return c.fields[0][t.Minute()] && c.fields[1][t.Hour()] && c.fields[2][t.Day()] &&
+		c.fields[3][int(t.Month())] && c.fields[4][int(t.Weekday())]
+}
+
+// step searches minute by minute for the closest matching time before or after t, up to a year away.
+func (c *cronSchedule) step(t time.Time, step int) (time.Time, error) {
+	t = t.Truncate(time.Minute)
+	for i := 0; i < 366*24*60; i++ {
+		t = t.Add(time.Duration(step) * time.Minute)
+		if c.matches(t) {
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("cron> schedule has no session within a year")
+}
+
+// TaskVars returns the variables visible to a task: vars, overridden by the _export blocks from the
+// root task down to the task, and by the params of the task itself. Like in Digdag, params given to an
+// attempt in vars don't override exported ones.
+func TaskVars(task *Task, vars map[string]interface{}) map[string]interface{} {
+	var chain []*Task
+	for t := task; t != nil; t = t.Parent() {
+		chain = append([]*Task{t}, chain...)
+	}
+	merged := MergeVars(nil, vars)
+	for _, t := range chain {
+		merged = MergeVars(merged, t.Export)
+	}
+	return MergeVars(merged, task.Params)
+}
+
+// MergeVars merges src into dst, merging nested maps like Digdag merges params, and returns dst.
+// A new map is returned when dst is nil.
+func MergeVars(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		sub, ok := v.(map[string]interface{})
+		existing, isMap := dst[k].(map[string]interface{})
+		if ok && isMap {
+			dst[k] = MergeVars(MergeVars(nil, existing), sub)
+			continue
+		}
+		if ok {
+			v = MergeVars(nil, sub)
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// TaskPreview is a task with the templates of its command and params rendered.
+type TaskPreview struct {
+	Task    *Task
+	Command interface{}
+	Params  map[string]interface{}
+	// Query is the rendered SQL file of SQL operators such as td>.
+	Query string
+}
+
+// Preview renders the task with the full name for the given variables, typically SessionVars merged with
+// the params of an attempt. The SQL file of SQL operators is read from fsys, relative to the workflow file,
+// unless fsys is nil.
+func Preview(fsys fs.FS, wf *Workflow, fullName string, vars map[string]interface{}) (*TaskPreview, error) {
+	task := wf.Root.Find(fullName)
+	if task == nil {
+		return nil, fmt.Errorf("task %s not found in workflow %s", fullName, wf.Name)
+	}
+	scope := TaskVars(task, vars)
+	preview := &TaskPreview{Task: task}
+	var err error
+	if preview.Command, err = RenderValue(task.Command, scope); err != nil {
+		return nil, fmt.Errorf("%s: %w", task.FullName, err)
+	}
+	params, err := RenderValue(task.Params, scope)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", task.FullName, err)
+	}
+	preview.Params, _ = params.(map[string]interface{})
+
+	command, ok := preview.Command.(string)
+	if fsys == nil || !ok || !sqlOperators[task.Operator] || !strings.HasSuffix(command, ".sql") {
+		return preview, nil
+	}
+	query, err := fs.ReadFile(fsys, path.Join(path.Dir(wf.File), command))
+	if err != nil {
+		return nil, err
+	}
+	if preview.Query, err = Render(string(query), scope); err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return preview, nil
+}
+
+// RenderValue renders the strings of a decoded value, such as the Command or Params of a task.
+func RenderValue(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return Render(v, vars)
+	case map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := RenderValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = r
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := RenderValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = r
+		}
+		return rendered, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,118 @@
+package dig
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSessionVars(t *testing.T) {
+	sessionTime := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	vars, err := SessionVars(SessionOptions{
+		SessionTime: sessionTime,
+		Timezone:    "Asia/Tokyo",
+		Schedule:    &Schedule{Kind: "daily", Value: "09:00:00"},
+		SessionID:   "42",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"timezone":                  "Asia/Tokyo",
+		"session_time":              "2024-03-01T09:00:00+09:00",
+		"session_date":              "2024-03-01",
+		"session_date_compact":      "20240301",
+		"session_local_time":        "2024-03-01 09:00:00",
+		"session_tz_offset":         "+0900",
+		"session_unixtime":          sessionTime.Unix(),
+		"last_session_date":         "2024-02-29",
+		"next_session_time":         "2024-03-02T09:00:00+09:00",
+		"last_session_date_compact": "20240229",
+		"session_id":                "42",
+	}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Errorf("%s wrong. want=%v, got=%v", name, value, vars[name])
+		}
+	}
+	if _, ok := vars["attempt_id"]; ok {
+		t.Error("attempt_id should be omitted")
+	}
+
+	vars, err = SessionVars(SessionOptions{SessionTime: sessionTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["session_time"] != "2024-03-01T00:00:00+00:00" || vars["last_session_time"] != nil {
+		t.Fatalf("vars without schedule wrong. got=%v", vars)
+	}
+}
+
+func TestStepSchedule(t *testing.T) {
+	at := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		kind, value string
+		last, next  time.Time
+	}{
+		{"hourly", "00:00", at.Add(-time.Hour), at.Add(time.Hour)},
+		{"weekly", "Mon,10:00:00", at.AddDate(0, 0, -7), at.AddDate(0, 0, 7)},
+		{"monthly", "4,10:00:00", at.AddDate(0, -1, 0), at.AddDate(0, 1, 0)},
+		{"minutes_interval", "15", at.Add(-15 * time.Minute), at.Add(15 * time.Minute)},
+		{"cron", "0 10 * * mon-fri", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)},
+		{"cron", "*/20 * * * *", at.Add(-20 * time.Minute), at.Add(20 * time.Minute)},
+	}
+	for _, tt := range tests {
+		s := &Schedule{Kind: tt.kind, Value: tt.value}
+		last, err := stepSchedule(s, at, -1)
+		if err != nil || !last.Equal(tt.last) {
+			t.Errorf("%s> %s: last wrong. want=%v, got=%v, %v", tt.kind, tt.value, tt.last, last, err)
+		}
+		next, err := stepSchedule(s, at, 1)
+		if err != nil || !next.Equal(tt.next) {
+			t.Errorf("%s> %s: next wrong. want=%v, got=%v, %v", tt.kind, tt.value, tt.next, next, err)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	fsys := fstest.MapFS{
+		"daily.dig": {Data: []byte(`_export:
+  td:
+    database: analytics
+  table: orders
+  days: 1
++load:
+  _export:
+    days: 7
+  td>: queries/load.sql
+  insert_into: ${table}_${session_date_compact}
+`)},
+		"queries/load.sql": {Data: []byte("select * from ${table}\nwhere time >= ${moment(session_time).subtract(days, 'days').unix()}\n")},
+	}
+	wf, err := Load(fsys, "daily.dig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := SessionVars(SessionOptions{SessionTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// exported params win over params of the attempt
+	vars["table"] = "ignored"
+	preview, err := Preview(fsys, wf, "+daily+load", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Command != "queries/load.sql" || preview.Params["insert_into"] != "orders_20240301" {
+		t.Fatalf("preview wrong. got=%+v", preview)
+	}
+	if want := "select * from orders\nwhere time >= 1708646400\n"; preview.Query != want {
+		t.Fatalf("query wrong. want=%q, got=%q", want, preview.Query)
+	}
+	if scope := TaskVars(preview.Task, nil); scope["td"].(map[string]interface{})["database"] != "analytics" {
+		t.Fatalf("task vars wrong. got=%v", scope)
+	}
+	if _, err := Preview(fsys, wf, "+daily+nope", vars); err == nil {
+		t.Fatal("expected an error for an unknown task")
+	}
+}
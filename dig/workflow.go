@@ -0,0 +1,212 @@
+// Package dig parses Digdag workflow definitions (.dig files) into a typed model.
+package dig
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow is a parsed workflow definition. The body of the definition is the root task.
+type Workflow struct {
+	// Name is the file name without the .dig extension.
+	Name     string
+	File     string
+	Timezone string
+	Schedule *Schedule
+	Root     *Task
+}
+
+// Schedule is the schedule: block of a workflow, e.g. daily>: 07:00:00.
+type Schedule struct {
+	// Kind is the schedule operator without ">", e.g. "daily" or "cron".
+	Kind  string
+	Value string
+	// Options holds the other keys such as start, end and skip_delayed_by.
+	Options map[string]interface{}
+	Line    int
+}
+
+// Task is a task of a workflow. A task without an operator is a group of its child tasks.
+type Task struct {
+	// Name is the key of the task including the "+" prefix. It is empty for the root task.
+	Name string
+	// FullName is the name the server gives the task, e.g. +daily+load+orders.
+	FullName string
+	// File is the file which defines the task. It differs from the workflow's when the task is included.
+	File string
+	Line int
+	// Operator is the operator type without ">", e.g. "td" for td>.
+	Operator string
+	// Command is the value of the operator key, e.g. the SQL file of td>.
+	Command interface{}
+	// Params holds the other keys of the task, such as database: or insert_into:.
+	Params     map[string]interface{}
+	Export     map[string]interface{}
+	Retry      *Retry
+	Parallel   Parallel
+	Background bool
+	Error      *Task
+	Check      *Task
+	// Do and ElseDo are the _do and _else_do tasks of loop>, for_each> and if>.
+	Do     *Task
+	ElseDo *Task
+	Tasks  []*Task
+	// Includes lists the !include directives of the task which were not resolved, as by Parse.
+	Includes []Include
+	// Node is the mapping node of the task.
+	Node *yaml.Node
+
+	// children holds the nested tasks in the order of the definition.
+	children []*Task
+	parent   *Task
+}
+
+// Retry is a _retry directive. Limit is the only field set by the short form _retry: N.
+type Retry struct {
+	Limit int
+	// Interval and MaxInterval are in seconds.
+	Interval    int
+	MaxInterval int
+	// IntervalType is "constant" or "exponential".
+	IntervalType string
+	// Expr holds the value when the directive is a ${...} expression.
+	Expr string
+	Line int
+}
+
+// Parallel is a _parallel directive.
+type Parallel struct {
+	Enabled bool
+	// Limit caps the number of child tasks running at once when set by _parallel: {limit: N}.
+	Limit int
+	// Expr holds the value when the directive is a ${...} expression.
+	Expr string
+}
+
+// Include is an !include directive.
+type Include struct {
+	Path string
+	Line int
+}
+
+// Error is a problem in a workflow definition.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+// IsGroup reports whether the task only groups child tasks.
+func (t *Task) IsGroup() bool {
+	return t.Operator == ""
+}
+
+// Children returns the tasks nested directly in the task in definition order, including
+// the _error, _check, _do and _else_do tasks.
+func (t *Task) Children() []*Task {
+	if t.children != nil {
+		return t.children
+	}
+	// built by hand rather than parsed
+	children := append([]*Task(nil), t.Tasks...)
+	for _, child := range []*Task{t.Do, t.ElseDo, t.Check, t.Error} {
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Config returns the keys of the task other than its child tasks, as the server shows them in Task.Config.
+func (t *Task) Config() map[string]interface{} {
+	config := map[string]interface{}{}
+	if t.Node == nil {
+		return config
+	}
+	for i := 0; i+1 < len(t.Node.Content); i += 2 {
+		key := t.Node.Content[i]
+		if isInclude(key) || strings.HasPrefix(key.Value, "+") {
+			continue
+		}
+		if v, err := decodeValue(t.Node.Content[i+1]); err == nil {
+			config[key.Value] = v
+		}
+	}
+	return config
+}
+
+// Parent returns the task which the task is nested in, or nil for the root task.
+func (t *Task) Parent() *Task {
+	return t.parent
+}
+
+// Find returns the task with the full name among the task and its nested tasks, or nil.
+func (t *Task) Find(fullName string) *Task {
+	if t.FullName == fullName {
+		return t
+	}
+	for _, child := range t.Children() {
+		if found := child.Find(fullName); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for the task and every nested task, depth first in definition order.
+// It stops at the first error, which it returns.
+func (t *Task) Walk(fn func(*Task) error) error {
+	if err := fn(t); err != nil {
+		return err
+	}
+	for _, child := range t.Children() {
+		if err := child.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyLine returns the line of a key of the task, or the line of the task when the key is missing.
+func (t *Task) KeyLine(key string) int {
+	if t.Node != nil {
+		for i := 0; i+1 < len(t.Node.Content); i += 2 {
+			if t.Node.Content[i].Value == key {
+				return t.Node.Content[i].Line
+			}
+		}
+	}
+	return t.Line
+}
+
+// KeyOrder returns the keys of the mapping under a key of the task in the order they're defined,
+// or nil when the value isn't a mapping.
+func (t *Task) KeyOrder(key string) []string {
+	if t.Node == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(t.Node.Content); i += 2 {
+		if t.Node.Content[i].Value != key {
+			continue
+		}
+		value := resolveAlias(t.Node.Content[i+1])
+		if value.Kind != yaml.MappingNode {
+			return nil
+		}
+		keys := make([]string, 0, len(value.Content)/2)
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			keys = append(keys, value.Content[j].Value)
+		}
+		return keys
+	}
+	return nil
+}
@@ -16,3 +16,14 @@ var (
 
 	ErrClient = errors.New("client Error")
 )
+
+// Operation Error
+var (
+	ErrKillTimeout = errors.New("timed out waiting for the attempt to be canceled")
+
+	ErrInvalidSecret = errors.New("invalid secret")
+
+	ErrLeakDetected = errors.New("credentials found in project archive")
+
+	ErrInvalidProject = errors.New("invalid project")
+)
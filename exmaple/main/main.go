@@ -66,7 +66,7 @@ func main() {
 		fmt.Println(err)
 		os.Exit(2)
 	}
-	fmt.Printf("%+v\n", secrets.Secrets...)
+	fmt.Printf("%+v\n", secrets.Keys())
 
 	sessions, err := client.GetProjectSessions(ctx, project.ID, "", "", "")
 	if err != nil {
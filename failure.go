@@ -0,0 +1,86 @@
+package digdaggo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// FailureSummary describes the task which failed first in an attempt.
+type FailureSummary struct {
+	Attempt Attempt `json:"attempt"`
+	// Task is the first failing task by time.
+	Task    Task   `json:"task"`
+	Message string `json:"message"`
+	// LogTail holds the last lines of the log of the task.
+	LogTail []string               `json:"logTail"`
+	Config  map[string]interface{} `json:"config"`
+	// Affected are the tasks which were canceled or blocked in the attempt.
+	Affected []Task `json:"affected"`
+}
+
+// FirstFailure summarizes the root cause of a failed attempt: the first failing task, its error message,
+// the last logLines lines of its log, its config, and the tasks which were canceled or blocked.
+// logLines defaults to 20.
+func (c *Client) FirstFailure(ctx context.Context, attemptId string, logLines int) (*FailureSummary, error) {
+	if logLines <= 0 {
+		logLines = 20
+	}
+	attempt, err := c.GetAttempt(ctx, attemptId)
+	if err != nil {
+		return nil, err
+	}
+	if !attempt.Done || attempt.Success {
+		return nil, fmt.Errorf("attempt %s has not failed", attemptId)
+	}
+	tasks, err := c.ListTasks(ctx, attemptId)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed, affected []Task
+	for _, task := range tasks.Tasks {
+		if task.IsGroup {
+			continue
+		}
+		switch task.State {
+		case "error":
+			failed = append(failed, task)
+		case "canceled", "blocked":
+			affected = append(affected, task)
+		}
+	}
+	if len(failed) == 0 {
+		return nil, fmt.Errorf("attempt %s has no failed task: %w", attemptId, ErrNotFound)
+	}
+	sort.SliceStable(failed, func(i, j int) bool {
+		a, b := failed[i], failed[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		}
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.Before(b.StartedAt)
+		}
+		idA, _ := strconv.ParseInt(a.ID, 10, 64)
+		idB, _ := strconv.ParseInt(b.ID, 10, 64)
+		return idA < idB
+	})
+	first := failed[0]
+
+	lines, err := c.GetTaskLogLines(ctx, attemptId, first.FullName)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > logLines {
+		lines = lines[len(lines)-logLines:]
+	}
+	return &FailureSummary{
+		Attempt:  *attempt,
+		Task:     first,
+		Message:  first.Error.Message,
+		LogTail:  lines,
+		Config:   first.Config,
+		Affected: affected,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package digdaggo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_FirstFailure(t *testing.T) {
+	var log bytes.Buffer
+	gz := gzip.NewWriter(&log)
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(gz, "line %d\n", i)
+	}
+	gz.Close()
+
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts/555": respondJSON(t, Attempt{ID: "555", Done: true}),
+		"GET /attempts/555/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": [
+				{"id": "1", "fullName": "+daily", "state": "group_error", "isGroup": true},
+				{"id": "2", "fullName": "+daily+a", "state": "error", "updatedAt": "2022-04-01T00:10:00Z", "error": {"message": "second"}},
+				{"id": "3", "fullName": "+daily+b", "state": "error", "updatedAt": "2022-04-01T00:05:00Z",
+				 "error": {"message": "Table not found"}, "config": {"td>": "queries/b.sql", "database": "db"}},
+				{"id": "4", "fullName": "+daily+c", "state": "blocked"},
+				{"id": "5", "fullName": "+daily+d", "state": "canceled"},
+				{"id": "6", "fullName": "+daily+e", "state": "success"}
+			]}`))
+		},
+		"GET /logs/555/files": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("task") != "+daily+b" {
+				t.Errorf("log of the wrong task requested: %s", req.URL.Query().Get("task"))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"files": [{"fileName": "b.log.gz", "taskName": "+daily+b"}]}`))
+		},
+		"GET /logs/555/files/b.log.gz": func(w http.ResponseWriter, req *http.Request) {
+			w.Write(log.Bytes())
+		},
+	})
+	defer teardown()
+
+	summary, err := client.FirstFailure(context.Background(), "555", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Task.FullName != "+daily+b" || summary.Message != "Table not found" || summary.Config["database"] != "db" {
+		t.Fatalf("first failure wrong. got=%+v", summary)
+	}
+	if got := strings.Join(summary.LogTail, ","); got != "line 26,line 27,line 28,line 29,line 30" {
+		t.Fatalf("log tail wrong. got=%s", got)
+	}
+	if len(summary.Affected) != 2 || summary.Affected[0].FullName != "+daily+c" || summary.Affected[1].FullName != "+daily+d" {
+		t.Fatalf("affected tasks wrong. got=%+v", summary.Affected)
+	}
+}
@@ -0,0 +1,161 @@
+package digdaggo
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// FlakyOptions configures FindFlakyTasks. Zero fields take their defaults.
+type FlakyOptions struct {
+	// Project and Workflow restrict the sessions which are walked.
+	Project  string
+	Workflow string
+	// Since ignores sessions whose latest attempt was created before. Defaults to 7 days ago.
+	Since time.Time
+	// MaxSessions bounds the number of sessions which are walked. Defaults to 100.
+	MaxSessions int
+	// Examples is the number of recent failures kept per task. Defaults to 3.
+	Examples int
+}
+
+// TaskFailure is an example failure of a task.
+type TaskFailure struct {
+	AttemptID   string    `json:"attemptId"`
+	SessionID   string    `json:"sessionId"`
+	SessionTime time.Time `json:"sessionTime"`
+	FailedAt    time.Time `json:"failedAt"`
+	Message     string    `json:"message"`
+}
+
+// FlakyTask summarizes how a task behaved across attempts.
+type FlakyTask struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	Task     string `json:"task"`
+	// Runs counts the attempts in which the task finished, successfully or not.
+	Runs     int `json:"runs"`
+	Failures int `json:"failures"`
+	// RetrySuccesses counts the failures which were followed by a success in a retry of the same session.
+	RetrySuccesses int `json:"retrySuccesses"`
+	// RecoveredByRetry counts the successful runs which needed the task's own _retry.
+	RecoveredByRetry int     `json:"recoveredByRetry"`
+	FailureRate      float64 `json:"failureRate"`
+	// RetrySuccessRatio is RetrySuccesses over Failures.
+	RetrySuccessRatio float64 `json:"retrySuccessRatio"`
+	// Flakiness is the share of runs which failed and then succeeded without a change, the ranking key.
+	Flakiness    float64       `json:"flakiness"`
+	LastFailures []TaskFailure `json:"lastFailures"`
+}
+
+// FindFlakyTasks walks recent sessions, their retries and task lists, and ranks the tasks which fail
+// intermittently and succeed on retry, flakiest first. Tasks which never failed are left out.
+func (c *Client) FindFlakyTasks(ctx context.Context, opts FlakyOptions) ([]FlakyTask, error) {
+	if opts.Since.IsZero() {
+		opts.Since = time.Now().Add(-7 * 24 * time.Hour)
+	}
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = 100
+	}
+	if opts.Examples <= 0 {
+		opts.Examples = 3
+	}
+
+	var latest []Attempt
+	err := c.forEachAttempt(ctx, opts.Project, opts.Workflow, false, func(a *Attempt) (bool, error) {
+		if a.CreatedAt.Before(opts.Since) {
+			return false, nil
+		}
+		latest = append(latest, *a)
+		return len(latest) < opts.MaxSessions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type taskKey struct{ project, workflow, task string }
+	tasks := map[taskKey]*FlakyTask{}
+	for i := range latest {
+		session, err := c.ListAttempts(ctx, latest[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		attempts := session.Attempts
+		if len(attempts) == 0 {
+			attempts = []Attempt{latest[i]}
+		}
+		sortAttemptsByCreation(attempts)
+
+		failedEarlier := map[taskKey]bool{}
+		for j := range attempts {
+			a := &attempts[j]
+			if !a.Done {
+				continue
+			}
+			list, err := c.ListTasks(ctx, a.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, task := range list.Tasks {
+				if task.IsGroup || task.State != "success" && task.State != "error" {
+					continue
+				}
+				key := taskKey{a.Project.Name, a.Workflow.Name, task.FullName}
+				ft, ok := tasks[key]
+				if !ok {
+					ft = &FlakyTask{Project: key.project, Workflow: key.workflow, Task: key.task}
+					tasks[key] = ft
+				}
+				ft.Runs++
+				if task.State == "error" {
+					ft.Failures++
+					failedEarlier[key] = true
+					ft.LastFailures = append(ft.LastFailures, TaskFailure{
+						AttemptID:   a.ID,
+						SessionID:   a.SessionID,
+						SessionTime: a.SessionTime,
+						FailedAt:    task.UpdatedAt,
+						Message:     task.Error.Message,
+					})
+					continue
+				}
+				if failedEarlier[key] {
+					ft.RetrySuccesses++
+					failedEarlier[key] = false
+				}
+				if taskRetryCount(&task) > 0 {
+					ft.RecoveredByRetry++
+				}
+			}
+		}
+	}
+
+	var flaky []FlakyTask
+	for _, ft := range tasks {
+		if ft.Failures == 0 && ft.RecoveredByRetry == 0 {
+			continue
+		}
+		ft.FailureRate = float64(ft.Failures) / float64(ft.Runs)
+		if ft.Failures > 0 {
+			ft.RetrySuccessRatio = float64(ft.RetrySuccesses) / float64(ft.Failures)
+		}
+		ft.Flakiness = float64(ft.RetrySuccesses+ft.RecoveredByRetry) / float64(ft.Runs)
+		sort.SliceStable(ft.LastFailures, func(i, j int) bool {
+			return ft.LastFailures[i].FailedAt.After(ft.LastFailures[j].FailedAt)
+		})
+		if len(ft.LastFailures) > opts.Examples {
+			ft.LastFailures = ft.LastFailures[:opts.Examples]
+		}
+		flaky = append(flaky, *ft)
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Flakiness != flaky[j].Flakiness {
+			return flaky[i].Flakiness > flaky[j].Flakiness
+		}
+		if flaky[i].Failures != flaky[j].Failures {
+			return flaky[i].Failures > flaky[j].Failures
+		}
+		return flaky[i].Task < flaky[j].Task
+	})
+	return flaky, nil
+}
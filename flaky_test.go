@@ -0,0 +1,72 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient_FindFlakyTasks(t *testing.T) {
+	now := time.Now()
+	attempt := func(id, session string, minutesAgo int, success bool) Attempt {
+		return Attempt{
+			ID:        id,
+			SessionID: session,
+			Project:   ProjectInAttempt{Name: "test"},
+			Workflow:  WorkflowInAttempt{Name: "daily"},
+			Done:      true,
+			Success:   success,
+			CreatedAt: now.Add(-time.Duration(minutesAgo) * time.Minute),
+		}
+	}
+	// session s1 failed on +load and succeeded on retry; session s2 failed on +report twice
+	s1First, s1Retry := attempt("1", "s1", 120, false), attempt("2", "s1", 60, true)
+	s2First, s2Retry := attempt("3", "s2", 50, false), attempt("4", "s2", 40, false)
+	tasks := map[string]string{
+		"1": `[{"fullName": "+daily+load", "state": "error", "updatedAt": "2022-04-01T00:00:00Z", "error": {"message": "timeout"}}, {"fullName": "+daily+report", "state": "blocked"}]`,
+		"2": `[{"fullName": "+daily+load", "state": "success"}, {"fullName": "+daily+report", "state": "success"}]`,
+		"3": `[{"fullName": "+daily+load", "state": "success", "stateParams": {"retry_count": 1}}, {"fullName": "+daily+report", "state": "error", "updatedAt": "2022-04-02T00:00:00Z", "error": {"message": "bad column"}}]`,
+		"4": `[{"fullName": "+daily+report", "state": "error", "updatedAt": "2022-04-02T01:00:00Z", "error": {"message": "bad column"}}]`,
+	}
+	routes := map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{s2Retry, s1Retry}})(w, req)
+		},
+		"GET /attempts/4/retries": respondJSON(t, AttemptList{Attempts: []Attempt{s2Retry, s2First}}),
+		"GET /attempts/2/retries": respondJSON(t, AttemptList{Attempts: []Attempt{s1Retry, s1First}}),
+	}
+	for id, list := range tasks {
+		list := list
+		routes["GET /attempts/"+id+"/tasks"] = func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": ` + list + `}`))
+		}
+	}
+	client, teardown := setupRoutes(t, routes)
+	defer teardown()
+
+	flaky, err := client.FindFlakyTasks(context.Background(), FlakyOptions{Project: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky) != 2 {
+		t.Fatalf("flaky tasks wrong. got=%+v", flaky)
+	}
+	load, report := flaky[0], flaky[1]
+	if load.Task != "+daily+load" || load.Runs != 3 || load.Failures != 1 || load.RetrySuccesses != 1 || load.RecoveredByRetry != 1 {
+		t.Fatalf("+load wrong. got=%+v", load)
+	}
+	if load.Flakiness != 2.0/3.0 || load.RetrySuccessRatio != 1 {
+		t.Fatalf("+load ratios wrong. got=%+v", load)
+	}
+	if report.Task != "+daily+report" || report.Failures != 2 || report.RetrySuccesses != 0 || report.Flakiness != 0 {
+		t.Fatalf("+report wrong. got=%+v", report)
+	}
+	if len(report.LastFailures) != 2 || report.LastFailures[0].AttemptID != "4" || report.LastFailures[0].Message != "bad column" {
+		t.Fatalf("last failures wrong. got=%+v", report.LastFailures)
+	}
+}
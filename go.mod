@@ -5,3 +5,5 @@ go 1.19
 require github.com/google/uuid v1.3.0
 
 require github.com/hashicorp/jsonapi v0.0.0-20210826224640-ee7dae0fb22d
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,122 @@
+package digdaggo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// LeakRule detects a kind of hard-coded credential.
+type LeakRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Allow suppresses a match of Pattern when it matches the matched text too, e.g. for templated values.
+	Allow *regexp.Regexp
+	// Files holds path.Match patterns of the base names of the files the rule applies to.
+	// The rule applies to every file when empty.
+	Files []string
+}
+
+// DefaultLeakRules are the rules used by WithLeakScan when no rules are given.
+var DefaultLeakRules = []LeakRule{
+	{
+		Name:    "td-api-key",
+		Pattern: regexp.MustCompile(`\b\d{1,8}/[0-9a-f]{40}\b`),
+	},
+	{
+		Name:    "aws-access-key-id",
+		Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Name:    "aws-secret-access-key",
+		Pattern: regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}[\s'":=]+[A-Za-z0-9/+=]{40}\b`),
+	},
+	{
+		Name:    "private-key",
+		Pattern: regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`),
+	},
+	{
+		Name:    "dig-password-param",
+		Pattern: regexp.MustCompile(`(?i)^\s*(?:[\w.-]*[._-])?(password|passwd|secret|token|api_?key)\s*:\s*\S+`),
+		Allow:   regexp.MustCompile(`:\s*['"]?\s*(\$\{|!include|['"]?\s*$)`),
+		Files:   []string{"*.dig", "*.yml", "*.yaml"},
+	},
+}
+
+// LeakFinding is a credential found in a file. Match is redacted.
+type LeakFinding struct {
+	Rule  string `json:"rule"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Match string `json:"match"`
+}
+
+func (f LeakFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.File, f.Line, f.Rule, f.Match)
+}
+
+// LeakError is returned by PutProject when the leak scan finds credentials.
+type LeakError struct {
+	Findings []LeakFinding
+}
+
+func (e *LeakError) Error() string {
+	lines := make([]string, 0, len(e.Findings))
+	for _, f := range e.Findings {
+		lines = append(lines, f.String())
+	}
+	return fmt.Sprintf("refusing to push, %d possible credentials found:\n%s", len(e.Findings), strings.Join(lines, "\n"))
+}
+
+func (e *LeakError) Unwrap() error {
+	return ErrLeakDetected
+}
+
+// ScanForLeaks inspects every text file of the archive with the rules.
+func ScanForLeaks(archive *ProjectArchive, rules []LeakRule) []LeakFinding {
+	var findings []LeakFinding
+	for _, name := range archive.Names() {
+		content := archive.Files[name]
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		var applicable []LeakRule
+		for _, rule := range rules {
+			if len(rule.Files) == 0 || matchesAny(path.Base(name), rule.Files) {
+				applicable = append(applicable, rule)
+			}
+		}
+		if len(applicable) == 0 {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := scanner.Text()
+			for _, rule := range applicable {
+				for _, match := range rule.Pattern.FindAllString(text, -1) {
+					if rule.Allow != nil && rule.Allow.MatchString(match) {
+						continue
+					}
+					findings = append(findings, LeakFinding{Rule: rule.Name, File: name, Line: line, Match: redact(match)})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// redact keeps only the beginning of a match so that reports don't leak the credential again.
+func redact(match string) string {
+	match = strings.TrimSpace(match)
+	keep := len(match) / 4
+	if keep > 6 {
+		keep = 6
+	}
+	return match[:keep] + strings.Repeat("*", 8)
+}
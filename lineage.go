@@ -0,0 +1,450 @@
+package digdaggo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"digdagGo/dig"
+)
+
+// TableUsage holds the tables a td> task reads and writes, as "database.table" in lower case.
+// Tables of which the database isn't known are given by name only.
+type TableUsage struct {
+	Project  string   `json:"project"`
+	Workflow string   `json:"workflow"`
+	Task     string   `json:"task"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Inputs   []string `json:"inputs"`
+	Outputs  []string `json:"outputs"`
+}
+
+// TableLineage links the td> tasks of projects by the tables they read and write.
+type TableLineage struct {
+	Tasks []TableUsage `json:"tasks"`
+	// Errors are the workflow and SQL files which can't be read, with the project name prefixed to the file.
+	Errors []dig.Diagnostic `json:"errors"`
+}
+
+// AnalyzeTableLineage finds the tables of the td> tasks in the workflows at the root of each project and
+// in the files they call>. Outputs are the insert_into and create_table params and the tables written by
+// the query; inputs are the tables the query reads with FROM and JOIN. Tables without a database get the
+// database param of the task or td.database of _export.
+func AnalyzeTableLineage(projects []ProjectFiles) *TableLineage {
+	l := &TableLineage{}
+	for _, p := range projects {
+		files, err := fs.Glob(p.FS, "*.dig")
+		if err != nil {
+			l.addError(p.Project.Name, dig.Diagnostic{File: ".", Message: err.Error()})
+			continue
+		}
+		for _, file := range files {
+			s := &lineageScan{lineage: l, fsys: p.FS, project: p.Project.Name, visited: map[string]bool{}}
+			s.load(file, "")
+		}
+	}
+	return l
+}
+
+func (l *TableLineage) addError(project string, d dig.Diagnostic) {
+	d.File = project + "/" + d.File
+	l.Errors = append(l.Errors, d)
+}
+
+// lineageScan collects the td> tasks of one workflow, following call> into files outside the root.
+type lineageScan struct {
+	lineage  *TableLineage
+	fsys     fs.FS
+	project  string
+	workflow string
+	visited  map[string]bool
+}
+
+func (s *lineageScan) load(file, workflow string) {
+	if s.visited[file] {
+		return
+	}
+	s.visited[file] = true
+	wf, err := dig.Load(s.fsys, file)
+	if err != nil {
+		s.lineage.addError(s.project, loadDiagnostic(file, err))
+		return
+	}
+	if workflow == "" {
+		s.workflow = wf.Name
+	}
+	dir := path.Dir(file)
+	wf.Root.Walk(func(t *dig.Task) error {
+		s.task(dir, t)
+		return nil
+	})
+}
+
+func (s *lineageScan) task(dir string, t *dig.Task) {
+	command, _ := t.Command.(string)
+	if t.Operator == "call" && !strings.Contains(command, "${") {
+		target := path.Join(dir, command)
+		if !strings.HasSuffix(target, ".dig") {
+			target += ".dig"
+		}
+		if path.Dir(target) != "." {
+			s.load(target, s.workflow)
+		}
+		return
+	}
+	if t.Operator != "td" {
+		return
+	}
+
+	database := tdDatabase(t)
+	usage := TableUsage{Project: s.project, Workflow: s.workflow, Task: t.FullName, File: s.project + "/" + t.File, Line: t.KeyLine("td>")}
+	query, _ := t.Params["query"].(string)
+	if strings.HasSuffix(command, ".sql") && !strings.Contains(command, "${") {
+		data, err := fs.ReadFile(s.fsys, path.Join(dir, command))
+		if err != nil {
+			s.lineage.addError(s.project, dig.Diagnostic{File: t.File, Line: usage.Line, Message: err.Error()})
+			return
+		}
+		query = string(data)
+	}
+	vars := dig.TaskVars(t, nil)
+	inputs, outputs := SQLTables(dig.RenderPartial(query, vars))
+	for _, param := range []string{"insert_into", "create_table"} {
+		if table, ok := t.Params[param].(string); ok && table != "" {
+			outputs = append(outputs, strings.ToLower(dig.RenderPartial(table, vars)))
+		}
+	}
+	usage.Inputs = qualifyTables(inputs, database)
+	usage.Outputs = qualifyTables(outputs, database)
+	s.lineage.Tasks = append(s.lineage.Tasks, usage)
+}
+
+// tdDatabase returns the database param of a td> task, which defaults to td.database of _export.
+func tdDatabase(t *dig.Task) string {
+	if database, ok := t.Params["database"].(string); ok {
+		return database
+	}
+	vars := dig.TaskVars(t, nil)
+	if td, ok := vars["td"].(map[string]interface{}); ok {
+		if database, ok := td["database"].(string); ok {
+			return database
+		}
+	}
+	database, _ := vars["database"].(string)
+	return database
+}
+
+func qualifyTables(tables []string, database string) []string {
+	seen := map[string]bool{}
+	qualified := []string{}
+	for _, table := range tables {
+		if !strings.Contains(table, ".") && database != "" {
+			table = strings.ToLower(database) + "." + table
+		}
+		if !seen[table] {
+			seen[table] = true
+			qualified = append(qualified, table)
+		}
+	}
+	sort.Strings(qualified)
+	return qualified
+}
+
+// GetTableLineage downloads the current revision of every project and analyzes their table lineage.
+func (c *Client) GetTableLineage(ctx context.Context) (*TableLineage, error) {
+	projects, err := c.GetProjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]ProjectFiles, 0, len(projects.Projects))
+	for _, project := range projects.Projects {
+		archive, err := c.GetProjectArchive(ctx, project.ID, project.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", project.Name, err)
+		}
+		files = append(files, ProjectFiles{Project: project, FS: archive.FS()})
+	}
+	return AnalyzeTableLineage(files), nil
+}
+
+// Tables returns every table read or written, in order.
+func (l *TableLineage) Tables() []string {
+	seen := map[string]bool{}
+	var tables []string
+	for _, usage := range l.Tasks {
+		for _, table := range append(append([]string(nil), usage.Inputs...), usage.Outputs...) {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Producers returns the tasks which write the table.
+func (l *TableLineage) Producers(table string) []TableUsage {
+	var tasks []TableUsage
+	for _, usage := range l.Tasks {
+		if containsString(usage.Outputs, strings.ToLower(table)) {
+			tasks = append(tasks, usage)
+		}
+	}
+	return tasks
+}
+
+// Consumers returns the tasks which read the table.
+func (l *TableLineage) Consumers(table string) []TableUsage {
+	var tasks []TableUsage
+	for _, usage := range l.Tasks {
+		if containsString(usage.Inputs, strings.ToLower(table)) {
+			tasks = append(tasks, usage)
+		}
+	}
+	return tasks
+}
+
+// Upstream returns the tables the table is derived from, directly or through other tables, in order.
+func (l *TableLineage) Upstream(table string) []string {
+	return l.reach(strings.ToLower(table), func(u TableUsage) ([]string, []string) { return u.Outputs, u.Inputs })
+}
+
+// Downstream returns the tables derived from the table, directly or through other tables, in order.
+func (l *TableLineage) Downstream(table string) []string {
+	return l.reach(strings.ToLower(table), func(u TableUsage) ([]string, []string) { return u.Inputs, u.Outputs })
+}
+
+// reach follows the tasks from the tables of one side to the tables of the other.
+func (l *TableLineage) reach(table string, sides func(TableUsage) (from, to []string)) []string {
+	seen := map[string]bool{table: true}
+	queue := []string{table}
+	var tables []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, usage := range l.Tasks {
+			from, to := sides(usage)
+			if !containsString(from, current) {
+				continue
+			}
+			for _, next := range to {
+				if !seen[next] {
+					seen[next] = true
+					tables = append(tables, next)
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// WriteDOT writes the lineage in the DOT language of Graphviz, with an edge from every input to every
+// output of a task, labeled with the workflow and the task.
+func (l *TableLineage) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph lineage {\n  rankdir=LR;\n  node [shape=cylinder];\n")
+	for _, table := range l.Tables() {
+		fmt.Fprintf(&b, "  %q;\n", table)
+	}
+	for _, usage := range l.Tasks {
+		label := usage.Project + "/" + usage.Workflow + " " + usage.Task
+		for _, input := range usage.Inputs {
+			for _, output := range usage.Outputs {
+				fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", input, output, label)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sqlCallKeywords are the words after which a parenthesis opens a subquery or a list, not a function call.
+var sqlCallKeywords = map[string]bool{
+	"select": true, "from": true, "join": true, "in": true, "as": true, "exists": true, "on": true, "where": true,
+	"and": true, "or": true, "not": true, "union": true, "all": true, "with": true, "any": true, "some": true,
+	"values": true, "into": true, "table": true, "lateral": true, "intersect": true, "except": true,
+}
+
+// SQLTables returns the tables a query reads with FROM and JOIN, and the tables it writes with INSERT INTO,
+// INSERT OVERWRITE TABLE, CREATE TABLE and DELETE FROM, in lower case. Names of WITH queries, subqueries and
+// FROM inside function calls such as EXTRACT(YEAR FROM t) are skipped.
+func SQLTables(query string) (inputs, outputs []string) {
+	tokens := sqlTokens(query)
+	ctes := map[string]bool{}
+	// the paren stack records whether each open parenthesis is a function call
+	var parens []bool
+	word := func(i int) string {
+		if i < len(tokens) {
+			return strings.ToLower(tokens[i])
+		}
+		return ""
+	}
+	for i := 0; i < len(tokens); i++ {
+		tok := word(i)
+		switch tok {
+		case "(":
+			call := i > 0 && isSQLName(tokens[i-1]) && !sqlCallKeywords[word(i-1)]
+			parens = append(parens, call)
+			continue
+		case ")":
+			if len(parens) > 0 {
+				parens = parens[:len(parens)-1]
+			}
+			continue
+		}
+		if len(parens) > 0 && parens[len(parens)-1] {
+			continue
+		}
+		switch {
+		case (tok == "with" || tok == ",") && isSQLName(word(i+1)) && word(i+2) == "as" && word(i+3) == "(":
+			ctes[word(i+1)] = true
+		case tok == "insert" && (word(i+1) == "into" || word(i+1) == "overwrite"):
+			j := i + 2
+			if word(j) == "table" {
+				j++
+			}
+			if isSQLName(word(j)) {
+				outputs = append(outputs, word(j))
+			}
+		case tok == "create" && word(i+1) == "table":
+			j := i + 2
+			if word(j) == "if" && word(j+1) == "not" && word(j+2) == "exists" {
+				j += 3
+			}
+			if isSQLName(word(j)) {
+				outputs = append(outputs, word(j))
+			}
+		case tok == "delete" && word(i+1) == "from" && isSQLName(word(i+2)):
+			outputs = append(outputs, word(i+2))
+			i++
+		case tok == "from" || tok == "join":
+			for j := i + 1; isSQLName(word(j)) && !sqlCallKeywords[word(j)] && word(j+1) != "("; {
+				inputs = append(inputs, word(j))
+				// skip an alias, then continue with the next table of a comma separated list
+				j++
+				if word(j) == "as" {
+					j++
+				}
+				if isSQLName(word(j)) && !isSQLClause(word(j)) {
+					j++
+				}
+				if word(j) != "," {
+					break
+				}
+				j++
+			}
+		}
+	}
+	var filtered []string
+	for _, table := range inputs {
+		if !ctes[table] {
+			filtered = append(filtered, table)
+		}
+	}
+	return filtered, outputs
+}
+
+// isSQLClause reports whether a word after a table starts the next part of the query rather than an alias.
+func isSQLClause(word string) bool {
+	switch word {
+	case "where", "join", "left", "right", "inner", "outer", "full", "cross", "on", "using", "group", "order",
+		"having", "limit", "union", "intersect", "except", "window", "natural", "select", "set", "values", "tablesample":
+		return true
+	}
+	return sqlCallKeywords[word]
+}
+
+func isSQLName(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	r := rune(tok[0])
+	return unicode.IsLetter(r) || r == '_' || r == '"' || r == '`' || r == '$'
+}
+
+// sqlTokens splits a query into names, such as db.table, "db"."table" or ${td.database}.table, and other
+// characters, skipping comments, string literals and numbers.
+func sqlTokens(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'':
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case isSQLNameByte(c) || c == '"' || c == '`' || strings.HasPrefix(query[i:], "${"):
+			end := sqlNameEnd(query, i)
+			if c < '0' || c > '9' {
+				tokens = append(tokens, strings.NewReplacer(`"`, "", "`", "").Replace(query[i:end]))
+			}
+			i = end
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+// sqlNameEnd returns the end of the name which starts at i.
+func sqlNameEnd(query string, i int) int {
+	for i < len(query) {
+		switch c := query[i]; {
+		case c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return len(query)
+			}
+			i += end + 2
+		case strings.HasPrefix(query[i:], "${"):
+			end := strings.IndexByte(query[i:], '}')
+			if end < 0 {
+				return len(query)
+			}
+			i += end + 1
+		case isSQLNameByte(c) || c == '.':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isSQLNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
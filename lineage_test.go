@@ -0,0 +1,107 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSQLTables(t *testing.T) {
+	tests := []struct {
+		query   string
+		inputs  []string
+		outputs []string
+	}{
+		{"select * from Events e join users u on e.uid = u.id", []string{"events", "users"}, nil},
+		{"SELECT a FROM raw.logs AS l, raw.hosts h WHERE l.ts > 0", []string{"raw.logs", "raw.hosts"}, nil},
+		{"with recent as (select * from logs) select * from recent left join \"dim\".\"users\" on true", []string{"logs", "dim.users"}, nil},
+		{"select extract(year from ts), count(*) from (select ts from a) t", []string{"a"}, nil},
+		{"-- from comment\nselect 'from quoted' /* from block */ from mart.b", []string{"mart.b"}, nil},
+		{"insert into summary select * from detail where id in (select id from keep)", []string{"detail", "keep"}, []string{"summary"}},
+		{"INSERT OVERWRITE TABLE out SELECT * FROM src", []string{"src"}, []string{"out"}},
+		{"create table if not exists tmp as select * from unnest(array[1]) t(x)", nil, []string{"tmp"}},
+		{"delete from old where ts < 0", nil, []string{"old"}},
+	}
+	for _, tt := range tests {
+		inputs, outputs := SQLTables(tt.query)
+		if !reflect.DeepEqual(inputs, tt.inputs) || !reflect.DeepEqual(outputs, tt.outputs) {
+			t.Errorf("SQLTables(%q) wrong. got=%v %v", tt.query, inputs, outputs)
+		}
+	}
+}
+
+func TestClient_GetTableLineage(t *testing.T) {
+	etl := buildArchive(t, map[string]string{
+		"daily.dig": `_export:
+  td:
+    database: raw
++load:
+  td>: queries/load.sql
+  insert_into: events_daily
++summary:
+  call>: lib/summary.dig
++missing:
+  td>: queries/missing.sql
+`,
+		"lib/summary.dig":        "+summarize:\n  td>: summary.sql\n  database: mart\n  create_table: summary\n",
+		"lib/summary.sql":        "select * from raw.events_daily join users using (uid)",
+		"queries/load.sql":       "select * from ${td.database}.b join events using (id) where d = '${session_date}'",
+		"queries/unrelated.dig":  "+a:\n  td>: a.sql\n",
+		"queries/unrelated.sql":  "select 1",
+		"scripts/unrelated.sh":   "echo",
+		"queries/load_older.sql": "select * from archive",
+	})
+	reports := buildArchive(t, map[string]string{
+		"weekly.dig": "+report:\n  td>:\n  query: select * from mart.summary\n  database: reports\n  insert_into: weekly_${session_date_compact}\n",
+	})
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects":           respondJSON(t, Projects{Projects: []Project{{ID: "1", Name: "etl", Revision: "r1"}, {ID: "2", Name: "reports", Revision: "r2"}}}),
+		"GET /projects/1/archive": func(w http.ResponseWriter, req *http.Request) { w.Write(etl) },
+		"GET /projects/2/archive": func(w http.ResponseWriter, req *http.Request) { w.Write(reports) },
+	})
+	defer teardown()
+
+	l, err := client.GetTableLineage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, usage := range l.Tasks {
+		got = append(got, usage.Project+"/"+usage.Workflow+usage.Task+" "+strings.Join(usage.Inputs, ",")+" -> "+strings.Join(usage.Outputs, ","))
+	}
+	want := []string{
+		"etl/daily+daily+load raw.b,raw.events -> raw.events_daily",
+		"etl/daily+summary+summarize mart.users,raw.events_daily -> mart.summary",
+		"reports/weekly+weekly+report mart.summary -> reports.weekly_${session_date_compact}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tasks wrong. got=\n%s", strings.Join(got, "\n"))
+	}
+	if len(l.Errors) != 1 || l.Errors[0].File != "etl/daily.dig" || l.Errors[0].Line != 10 {
+		t.Fatalf("errors wrong. got=%+v", l.Errors)
+	}
+
+	if producers := l.Producers("Mart.Summary"); len(producers) != 1 || producers[0].Task != "+summary+summarize" || producers[0].File != "etl/lib/summary.dig" {
+		t.Fatalf("producers wrong. got=%+v", producers)
+	}
+	if consumers := l.Consumers("mart.summary"); len(consumers) != 1 || consumers[0].Workflow != "weekly" {
+		t.Fatalf("consumers wrong. got=%+v", consumers)
+	}
+	if upstream := l.Upstream("reports.weekly_${session_date_compact}"); !reflect.DeepEqual(upstream, []string{"mart.summary", "mart.users", "raw.b", "raw.events", "raw.events_daily"}) {
+		t.Fatalf("upstream wrong. got=%v", upstream)
+	}
+	if downstream := l.Downstream("raw.events"); !reflect.DeepEqual(downstream, []string{"mart.summary", "raw.events_daily", "reports.weekly_${session_date_compact}"}) {
+		t.Fatalf("downstream wrong. got=%v", downstream)
+	}
+
+	var dot bytes.Buffer
+	if err := l.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `"mart.summary" -> "reports.weekly_${session_date_compact}" [label="reports/weekly +weekly+report"];`) {
+		t.Fatalf("DOT wrong. got=\n%s", dot.String())
+	}
+}
@@ -0,0 +1,489 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"digdagGo/dig"
+)
+
+// maxCallDepth bounds nested call> tasks in local runs.
+const maxCallDepth = 16
+
+// LocalTask is the task which an OperatorStub simulates, with its command and params rendered.
+type LocalTask struct {
+	FullName string
+	Operator string
+	Command  interface{}
+	Params   map[string]interface{}
+	// Vars are the variables visible to the task.
+	Vars map[string]interface{}
+}
+
+// OperatorStub simulates an operator in a local run. It returns the params the task stores for the
+// following tasks, e.g. {"td": {"last_job_id": 1}}, or an error to fail the task.
+type OperatorStub func(ctx context.Context, task LocalTask) (map[string]interface{}, error)
+
+// LocalRunOptions configures RunLocal.
+type LocalRunOptions struct {
+	// SessionTime defaults to now.
+	SessionTime time.Time
+	// Params are the params of the attempt.
+	Params map[string]interface{}
+	// Stubs simulate operators by type, e.g. "td". A stub takes precedence over the local
+	// implementation of echo>, sh>, fail> and the control operators.
+	Stubs map[string]OperatorStub
+	// RequireStubs fails tasks of remote operators without a stub. Otherwise they succeed without effect.
+	RequireStubs bool
+	// Dir is the directory in which sh> commands run, usually the directory of fsys.
+	Dir string
+	// Output receives the output of echo> and sh>. It defaults to io.Discard.
+	Output io.Writer
+}
+
+// RunLocal runs a workflow of a project file system locally to test its control flow, and returns its
+// tasks like ListTasks does. echo>, sh>, fail>, call>, loop>, for_each> and if> run locally; other operators
+// are simulated by Stubs. _export, _retry, _error, _check and _parallel are honoured, but the children of
+// _parallel groups run one after another: like on the server, a failure doesn't stop their siblings.
+// Retries don't wait. When a task fails, the tasks are returned along with an error.
+func RunLocal(ctx context.Context, fsys fs.FS, workflowFile string, opts LocalRunOptions) (*TasksList, error) {
+	wf, err := dig.Load(fsys, workflowFile)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SessionTime.IsZero() {
+		opts.SessionTime = time.Now()
+	}
+	if opts.Output == nil {
+		opts.Output = io.Discard
+	}
+	vars, err := dig.SessionVars(dig.SessionOptions{SessionTime: opts.SessionTime, Timezone: wf.Timezone, Schedule: wf.Schedule})
+	if err != nil {
+		return nil, err
+	}
+	r := &localRun{ctx: ctx, fsys: fsys, opts: opts, vars: dig.MergeVars(vars, opts.Params), stored: map[string]interface{}{}}
+	root := r.run(path.Dir(workflowFile), wf.Root, wf.Root.FullName, nil, nil, 0)
+
+	tasks := &TasksList{}
+	flattenLocal(root, "", tasks)
+	if root.task.State == "success" {
+		return tasks, nil
+	}
+	for _, task := range tasks.Tasks {
+		if task.State == "error" {
+			return tasks, fmt.Errorf("local run of %s failed at %s: %s", wf.Name, task.FullName, task.Error.Message)
+		}
+	}
+	return tasks, fmt.Errorf("local run of %s failed: %s", wf.Name, root.task.Error.Message)
+}
+
+type localRun struct {
+	ctx  context.Context
+	fsys fs.FS
+	opts LocalRunOptions
+	vars map[string]interface{}
+	// stored holds the params stored by the tasks which ran so far.
+	stored map[string]interface{}
+}
+
+// localNode is a task of a local run. IDs and upstreams are assigned once the run is over.
+type localNode struct {
+	task     Task
+	children []*localNode
+	// upstream is the index of the sibling the task waits for, or -1.
+	upstream int
+}
+
+// localChild is a task to run as the child of another one.
+type localChild struct {
+	task   *dig.Task
+	name   string
+	export map[string]interface{}
+}
+
+func (r *localRun) newNode(t *dig.Task, fullName string) *localNode {
+	return &localNode{
+		task: Task{
+			FullName:     fullName,
+			Config:       t.Config(),
+			State:        "planned",
+			ExportParams: t.Export,
+			StoreParams:  map[string]interface{}{},
+			StateParams:  map[string]interface{}{},
+			IsGroup:      t.IsGroup(),
+		},
+		upstream: -1,
+	}
+}
+
+// run runs a task with its retries, _check and _error tasks. exports holds the _export blocks of the
+// enclosing tasks, innermost last.
+func (r *localRun) run(dir string, t *dig.Task, fullName string, exports []map[string]interface{}, extra map[string]interface{}, depth int) *localNode {
+	n := r.newNode(t, fullName)
+	n.task.StartedAt = time.Now()
+	exports = append(exports[:len(exports):len(exports)], extra, t.Export)
+
+	limit := 0
+	var err error
+	if t.Retry != nil {
+		limit = t.Retry.Limit
+		if t.Retry.Expr != "" {
+			limit, err = r.renderInt(t.Retry.Expr, exports, t.Params)
+		}
+	}
+	for retry := 0; err == nil; retry++ {
+		if err = r.ctx.Err(); err != nil {
+			break
+		}
+		n.children = nil
+		err = r.execute(dir, t, n, exports, depth)
+		if err == nil && t.Check != nil {
+			check := r.run(dir, t.Check, fullName+"^check", exports, nil, depth)
+			n.children = append(n.children, check)
+			if check.task.State != "success" {
+				err = fmt.Errorf("%s^check failed", fullName)
+			}
+		}
+		if err == nil || retry >= limit {
+			break
+		}
+		n.task.StateParams["retry_count"] = retry + 1
+		err = nil
+	}
+
+	n.task.UpdatedAt = time.Now()
+	if err == nil {
+		n.task.State = "success"
+		return n
+	}
+	n.task.State = "error"
+	if n.task.IsGroup {
+		n.task.State = "group_error"
+	}
+	n.task.Error = TaskError{Message: err.Error()}
+	if t.Error != nil {
+		n.children = append(n.children, r.run(dir, t.Error, fullName+"^error", exports, nil, depth))
+	}
+	return n
+}
+
+// scope returns the variables of a task: built-in variables and params of the attempt, stored params,
+// exported params and the params of the task, in increasing priority.
+func (r *localRun) scope(exports []map[string]interface{}, params map[string]interface{}) map[string]interface{} {
+	scope := dig.MergeVars(dig.MergeVars(nil, r.vars), r.stored)
+	for _, export := range exports {
+		scope = dig.MergeVars(scope, export)
+	}
+	return dig.MergeVars(scope, params)
+}
+
+func (r *localRun) renderInt(expr string, exports []map[string]interface{}, params map[string]interface{}) (int, error) {
+	s, err := dig.Render(expr, r.scope(exports, params))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(s))
+}
+
+func (r *localRun) execute(dir string, t *dig.Task, n *localNode, exports []map[string]interface{}, depth int) error {
+	if t.IsGroup() {
+		children := make([]localChild, 0, len(t.Tasks))
+		for _, sub := range t.Tasks {
+			children = append(children, localChild{task: sub, name: n.task.FullName + sub.Name})
+		}
+		parallel, err := r.parallel(t, exports)
+		if err != nil {
+			return err
+		}
+		return r.runChildren(dir, n, children, exports, parallel, depth)
+	}
+
+	scope := r.scope(exports, t.Params)
+	command, err := dig.RenderValue(t.Command, scope)
+	if err != nil {
+		return err
+	}
+	rendered, err := dig.RenderValue(t.Params, scope)
+	if err != nil {
+		return err
+	}
+	params, _ := rendered.(map[string]interface{})
+
+	if stub, ok := r.opts.Stubs[t.Operator]; ok {
+		store, err := stub(r.ctx, LocalTask{FullName: n.task.FullName, Operator: t.Operator, Command: command, Params: params, Vars: scope})
+		r.store(n, store)
+		return err
+	}
+	switch t.Operator {
+	case "echo":
+		_, err := fmt.Fprintln(r.opts.Output, localString(command))
+		return err
+	case "fail":
+		return errors.New(localString(command))
+	case "sh":
+		return r.shell(dir, localString(command), params)
+	case "call":
+		if depth >= maxCallDepth {
+			return fmt.Errorf("call> is nested too deeply")
+		}
+		target := path.Join(dir, localString(command))
+		if !strings.HasSuffix(target, ".dig") {
+			target += ".dig"
+		}
+		called, err := dig.Load(r.fsys, target)
+		if err != nil {
+			return err
+		}
+		return r.runChildren(path.Dir(target), n, []localChild{{task: called.Root, name: n.task.FullName + "^sub"}}, exports, false, depth+1)
+	case "if":
+		do := t.ElseDo
+		if localBool(command) {
+			do = t.Do
+		}
+		if do == nil {
+			return nil
+		}
+		return r.runChildren(dir, n, []localChild{{task: do, name: n.task.FullName + "^sub"}}, exports, false, depth)
+	case "loop":
+		count, err := strconv.Atoi(strings.TrimSpace(localString(command)))
+		if err != nil {
+			return fmt.Errorf("loop> needs a number: %w", err)
+		}
+		var children []localChild
+		for i := 0; i < count; i++ {
+			children = append(children, localChild{task: t.Do, name: fmt.Sprintf("+loop-%d", i), export: map[string]interface{}{"i": i}})
+		}
+		return r.runSub(dir, t, n, children, exports, depth)
+	case "for_each":
+		children, err := forEachChildren(t, command)
+		if err != nil {
+			return err
+		}
+		return r.runSub(dir, t, n, children, exports, depth)
+	}
+	if r.opts.RequireStubs {
+		return fmt.Errorf("no stub for %s>", t.Operator)
+	}
+	return nil
+}
+
+// runSub runs the iterations of loop> and for_each> in a ^sub group.
+func (r *localRun) runSub(dir string, t *dig.Task, n *localNode, children []localChild, exports []map[string]interface{}, depth int) error {
+	if t.Do == nil {
+		return fmt.Errorf("%s> needs _do", t.Operator)
+	}
+	sub := &localNode{
+		task:     Task{FullName: n.task.FullName + "^sub", State: "planned", IsGroup: true, StartedAt: time.Now(), StoreParams: map[string]interface{}{}, StateParams: map[string]interface{}{}},
+		upstream: -1,
+	}
+	for i := range children {
+		children[i].name = sub.task.FullName + children[i].name
+	}
+	n.children = append(n.children, sub)
+	parallel, err := r.parallel(t, exports)
+	if err == nil {
+		err = r.runChildren(dir, sub, children, exports, parallel, depth)
+	}
+	sub.task.UpdatedAt = time.Now()
+	sub.task.State = "success"
+	if err != nil {
+		sub.task.State = "group_error"
+		sub.task.Error = TaskError{Message: err.Error()}
+	}
+	return err
+}
+
+// runChildren runs tasks one after another. Unless parallel, the tasks following a failed one are canceled.
+func (r *localRun) runChildren(dir string, parent *localNode, children []localChild, exports []map[string]interface{}, parallel bool, depth int) error {
+	failed := 0
+	for i, child := range children {
+		var node *localNode
+		if failed > 0 && !parallel {
+			node = r.newNode(child.task, child.name)
+			node.task.State = "canceled"
+		} else {
+			node = r.run(dir, child.task, child.name, exports, child.export, depth)
+			if node.task.State != "success" {
+				failed++
+			}
+		}
+		if !parallel && i > 0 {
+			node.upstream = len(parent.children) - 1
+		}
+		parent.children = append(parent.children, node)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d child tasks failed", failed, len(children))
+	}
+	return nil
+}
+
+func (r *localRun) parallel(t *dig.Task, exports []map[string]interface{}) (bool, error) {
+	if t.Parallel.Expr == "" {
+		return t.Parallel.Enabled, nil
+	}
+	s, err := dig.Render(t.Parallel.Expr, r.scope(exports, t.Params))
+	if err != nil {
+		return false, err
+	}
+	return localBool(s), nil
+}
+
+func (r *localRun) store(n *localNode, params map[string]interface{}) {
+	if len(params) == 0 {
+		return
+	}
+	n.task.StoreParams = dig.MergeVars(n.task.StoreParams, params)
+	r.stored = dig.MergeVars(r.stored, params)
+}
+
+func (r *localRun) shell(dir, command string, params map[string]interface{}) error {
+	cmd := exec.CommandContext(r.ctx, "sh", "-c", command)
+	cmd.Dir = filepath.Join(r.opts.Dir, filepath.FromSlash(dir))
+	cmd.Env = os.Environ()
+	if env, ok := params["_env"].(map[string]interface{}); ok {
+		for _, key := range sortedParamKeys(env) {
+			cmd.Env = append(cmd.Env, key+"="+localString(env[key]))
+		}
+	}
+	cmd.Stdout = r.opts.Output
+	cmd.Stderr = r.opts.Output
+	return cmd.Run()
+}
+
+// forEachChildren expands the for_each> values into the combinations of their keys, keys in the order
+// they're defined like on the server.
+func forEachChildren(t *dig.Task, command interface{}) ([]localChild, error) {
+	defined := t.KeyOrder("for_each>")
+	if s, ok := command.(string); ok {
+		defined = jsonKeyOrder(s)
+		if err := json.Unmarshal([]byte(s), &command); err != nil {
+			return nil, fmt.Errorf("for_each> needs a mapping: %w", err)
+		}
+	}
+	values, ok := command.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("for_each> needs a mapping")
+	}
+	keys := make([]string, 0, len(values))
+	for _, key := range defined {
+		if _, ok := values[key]; ok && !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range sortedParamKeys(values) {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	combinations := []map[string]interface{}{{}}
+	for _, key := range keys {
+		items, ok := values[key].([]interface{})
+		if s, isString := values[key].(string); isString {
+			ok = json.Unmarshal([]byte(s), &items) == nil
+		}
+		if !ok {
+			return nil, fmt.Errorf("for_each> values of %s must be a list", key)
+		}
+		var next []map[string]interface{}
+		for _, combination := range combinations {
+			for _, item := range items {
+				c := dig.MergeVars(nil, combination)
+				c[key] = item
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+	children := make([]localChild, 0, len(combinations))
+	for i, combination := range combinations {
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, key+"="+localString(combination[key]))
+		}
+		children = append(children, localChild{task: t.Do, name: fmt.Sprintf("+for-%d=%s", i, strings.Join(pairs, "&")), export: combination})
+	}
+	return children, nil
+}
+
+// jsonKeyOrder returns the keys of a JSON object in the order they're written, or nil for other JSON.
+func jsonKeyOrder(s string) []string {
+	dec := json.NewDecoder(strings.NewReader(s))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// flattenLocal assigns IDs in definition order and appends the tasks to the list.
+func flattenLocal(n *localNode, parentID string, tasks *TasksList) {
+	n.task.ID = strconv.Itoa(len(tasks.Tasks) + 1)
+	n.task.ParentID = parentID
+	tasks.Tasks = append(tasks.Tasks, n.task)
+	ids := make([]string, len(n.children))
+	for i, child := range n.children {
+		if child.upstream >= 0 {
+			child.task.Upstreams = []string{ids[child.upstream]}
+		}
+		flattenLocal(child, n.task.ID, tasks)
+		ids[i] = child.task.ID
+	}
+}
+
+func localString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func localBool(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	}
+	return false
+}
+
+func sortedParamKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
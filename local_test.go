@@ -0,0 +1,196 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"digdagGo/dig"
+)
+
+func TestRunLocal(t *testing.T) {
+	fsys := fstest.MapFS{
+		"daily.dig": {Data: []byte(`timezone: UTC
+_export:
+  table: orders
++prepare:
+  echo>: preparing ${table} for ${session_date}
++load:
+  td>: queries/load.sql
+  database: analytics
++report:
+  echo>: job ${td.last_job_id}
++regions:
+  for_each>:
+    region: [us, eu]
+    env: [prod]
+  _do:
+    echo>: region ${region}
++twice:
+  loop>: 2
+  _do:
+    echo>: loop ${i}
++branch:
+  if>: ${table == 'orders'}
+  _do:
+    call>: sub/child
+  _else_do:
+    echo>: skipped
+`)},
+		"sub/child.dig": {Data: []byte("+child:\n  echo>: child of ${table}\n")},
+	}
+	var loads []LocalTask
+	var out bytes.Buffer
+	tasks, err := RunLocal(context.Background(), fsys, "daily.dig", LocalRunOptions{
+		SessionTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Params:      map[string]interface{}{"table": "ignored"},
+		Output:      &out,
+		Stubs: map[string]OperatorStub{
+			"td": func(ctx context.Context, task LocalTask) (map[string]interface{}, error) {
+				loads = append(loads, task)
+				return map[string]interface{}{"td": map[string]interface{}{"last_job_id": 42}}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOut := "preparing orders for 2024-03-01\njob 42\nregion us\nregion eu\nloop 0\nloop 1\nchild of orders\n"
+	if out.String() != wantOut {
+		t.Fatalf("output wrong. got=%q", out.String())
+	}
+	if len(loads) != 1 || loads[0].Command != "queries/load.sql" || loads[0].Params["database"] != "analytics" {
+		t.Fatalf("stub calls wrong. got=%+v", loads)
+	}
+
+	var names []string
+	byName := map[string]Task{}
+	for _, task := range tasks.Tasks {
+		names = append(names, task.FullName)
+		byName[task.FullName] = task
+		if task.State != "success" {
+			t.Errorf("%s: state wrong. got=%s", task.FullName, task.State)
+		}
+	}
+	want := []string{
+		"+daily", "+daily+prepare", "+daily+load", "+daily+report",
+		"+daily+regions", "+daily+regions^sub", "+daily+regions^sub+for-0=region=us&env=prod", "+daily+regions^sub+for-1=region=eu&env=prod",
+		"+daily+twice", "+daily+twice^sub", "+daily+twice^sub+loop-0", "+daily+twice^sub+loop-1",
+		"+daily+branch", "+daily+branch^sub", "+daily+branch^sub^sub", "+daily+branch^sub^sub+child",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("tasks wrong. got=%v", names)
+	}
+	if load := byName["+daily+load"]; load.ParentID != "1" || len(load.Upstreams) != 1 || load.Upstreams[0] != byName["+daily+prepare"].ID {
+		t.Fatalf("load wrong. got=%+v", load)
+	}
+	if !byName["+daily"].IsGroup || byName["+daily+load"].StoreParams["td"] == nil || byName["+daily+load"].Config["database"] != "analytics" {
+		t.Fatalf("task details wrong. got=%+v", byName["+daily+load"])
+	}
+}
+
+func TestRunLocalFailures(t *testing.T) {
+	fsys := fstest.MapFS{
+		"flaky.dig": {Data: []byte(`_error:
+  +notify:
+    echo>: failed
++steps:
+  _retry: 2
+  +flaky:
+    td>: flaky.sql
+  +after:
+    echo>: after
++parallel:
+  _parallel: true
+  +a:
+    fail>: broken
+  +b:
+    echo>: b
++never:
+  echo>: never
+`)},
+	}
+	calls := 0
+	var out bytes.Buffer
+	tasks, err := RunLocal(context.Background(), fsys, "flaky.dig", LocalRunOptions{
+		Output: &out,
+		Stubs: map[string]OperatorStub{
+			"td": func(ctx context.Context, task LocalTask) (map[string]interface{}, error) {
+				calls++
+				if calls < 3 {
+					return nil, errors.New("timeout")
+				}
+				return nil, nil
+			},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "+flaky+parallel+a: broken") {
+		t.Fatalf("error wrong. got=%v", err)
+	}
+	if calls != 3 || out.String() != "after\nb\nfailed\n" {
+		t.Fatalf("run wrong. calls=%d, output=%q", calls, out.String())
+	}
+	states := map[string]string{}
+	for _, task := range tasks.Tasks {
+		states[task.FullName] = task.State
+		if task.FullName == "+flaky+steps" && task.StateParams["retry_count"] != 2 {
+			t.Errorf("retry count wrong. got=%v", task.StateParams)
+		}
+	}
+	want := map[string]string{
+		"+flaky":              "group_error",
+		"+flaky+steps":        "success",
+		"+flaky+steps+flaky":  "success",
+		"+flaky+steps+after":  "success",
+		"+flaky+parallel":     "group_error",
+		"+flaky+parallel+a":   "error",
+		"+flaky+parallel+b":   "success",
+		"+flaky+never":        "canceled",
+		"+flaky^error":        "success",
+		"+flaky^error+notify": "success",
+	}
+	if len(states) != len(want) {
+		t.Fatalf("tasks wrong. got=%v", states)
+	}
+	for name, state := range want {
+		if states[name] != state {
+			t.Errorf("%s: state wrong. want=%s, got=%s", name, state, states[name])
+		}
+	}
+
+	_, err = RunLocal(context.Background(), fstest.MapFS{"a.dig": {Data: []byte("+a:\n  td>: a.sql\n")}}, "a.dig", LocalRunOptions{RequireStubs: true})
+	if err == nil || !strings.Contains(err.Error(), "no stub for td>") {
+		t.Fatalf("expected a missing stub error. got=%v", err)
+	}
+}
+
+func TestRunLocalShell(t *testing.T) {
+	dir := t.TempDir()
+	fsys := fstest.MapFS{"a.dig": {Data: []byte("+a:\n  sh>: echo $GREETING $PWD\n  _env:\n    GREETING: hello\n+b:\n  sh>: exit 3\n")}}
+	var out bytes.Buffer
+	_, err := RunLocal(context.Background(), fsys, "a.dig", LocalRunOptions{Dir: dir, Output: &out})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("error wrong. got=%v", err)
+	}
+	if !strings.HasPrefix(out.String(), "hello ") || !strings.Contains(out.String(), dir) {
+		t.Fatalf("output wrong. got=%q", out.String())
+	}
+}
+
+func TestForEachChildren_jsonKeyOrder(t *testing.T) {
+	children, err := forEachChildren(&dig.Task{}, `{"region": ["us"], "env": ["prod", "dev"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, child := range children {
+		names = append(names, child.name)
+	}
+	if want := "+for-0=region=us&env=prod,+for-1=region=us&env=dev"; strings.Join(names, ",") != want {
+		t.Fatalf("children wrong. got=%v", names)
+	}
+}
@@ -1,8 +1,13 @@
 package digdaggo
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -18,13 +23,13 @@ type Files struct {
 	} `json:"files"`
 }
 
-func (c *Client) getLogList(ctx context.Context, attemptId int, task string, direct bool) (*Files, error) {
+func (c *Client) getLogList(ctx context.Context, attemptId string, task string, direct bool) (*Files, error) {
 	parameters := map[string]string{}
 	if task != "" {
 		parameters["task"] = task
 	}
 	parameters["direct_download"] = strconv.FormatBool(direct)
-	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("logs/%d/files", attemptId), parameters, nil, nil)
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("logs/%s/files", attemptId), parameters, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +48,74 @@ func (c *Client) getLogList(ctx context.Context, attemptId int, task string, dir
 	}
 	return &files, nil
 }
+
+func (c *Client) getLogFile(ctx context.Context, attemptId, fileName string) ([]byte, error) {
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("logs/%s/files/%s", attemptId, fileName), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkError := c.checkHttpResponseCode(resp)
+	if checkError != nil {
+		return nil, checkError
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// log files are stored gzipped, but may be served decompressed
+	if len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b {
+		return body, nil
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	return io.ReadAll(gzr)
+}
+
+// GetTaskLog downloads the log of a task of an attempt, oldest log file first.
+// The log of the whole attempt is returned when taskName is empty.
+func (c *Client) GetTaskLog(ctx context.Context, attemptId, taskName string) ([]byte, error) {
+	files, err := c.getLogList(ctx, attemptId, taskName, false)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files.File, func(i, j int) bool {
+		return files.File[i].FileTime.Before(files.File[j].FileTime)
+	})
+	var log bytes.Buffer
+	for _, f := range files.File {
+		content, err := c.getLogFile(ctx, attemptId, f.FileName)
+		if err != nil {
+			return nil, err
+		}
+		log.Write(content)
+	}
+	return log.Bytes(), nil
+}
+
+// GetTaskLogLines is GetTaskLog split into lines.
+func (c *Client) GetTaskLogLines(ctx context.Context, attemptId, taskName string) ([]string, error) {
+	log, err := c.GetTaskLog(ctx, attemptId, taskName)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
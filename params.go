@@ -0,0 +1,298 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"digdagGo/dig"
+)
+
+// WithParamsValidation checks the params with ValidateProjectParams before the attempt is started.
+// Params which only scripts of sh> or py> read can't be found that way, so validation is opt-in.
+func WithParamsValidation() AttemptOption {
+	return func(o *attemptOptions) {
+		o.validate = true
+	}
+}
+
+// StartAttemptWithParams starts an attempt with params declared as a Go type.
+func StartAttemptWithParams[P any](ctx context.Context, c *Client, params P, workflowID int64, sessionTime time.Time, opts ...AttemptOption) (*Attempt, error) {
+	if newAttemptOptions(opts).validate {
+		wf, err := c.GetWorkflowWithID(ctx, strconv.FormatInt(workflowID, 10))
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ValidateProjectParams(ctx, wf, params); err != nil {
+			return nil, err
+		}
+	}
+	return c.StartAttempt(ctx, params, workflowID, sessionTime, opts...)
+}
+
+// StartWorkflowWithParams is StartWorkflow with params declared as a Go type.
+// opts.Params is replaced by params, and opts.SessionTimeMode by the mode of WithSessionTimeMode when given.
+func StartWorkflowWithParams[P any](ctx context.Context, c *Client, opts StartWorkflowOptions, params P, attemptOpts ...AttemptOption) (*Attempt, error) {
+	o := newAttemptOptions(attemptOpts)
+	if o.sessionTimeMode != "" {
+		opts.SessionTimeMode = o.sessionTimeMode
+	}
+	if o.validate {
+		wf, err := c.ResolveWorkflowAtRevision(ctx, opts.Project+"/"+opts.Workflow, opts.Revision)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ValidateProjectParams(ctx, wf, params); err != nil {
+			return nil, err
+		}
+	}
+	opts.Params = params
+	return c.StartWorkflow(ctx, opts)
+}
+
+// RetryAttemptWithParams is RetryAttempt with override params declared as a Go type.
+// opts.Params is replaced by params, which are validated against the workflow the retry runs.
+func RetryAttemptWithParams[P any](ctx context.Context, c *Client, attemptId string, opts RetryOptions, params P, attemptOpts ...AttemptOption) (*Attempt, error) {
+	overrides, err := paramsMap(params)
+	if err != nil {
+		return nil, err
+	}
+	opts.Params = overrides
+	body, wf, err := c.newRetryAttemptBody(ctx, attemptId, opts)
+	if err != nil {
+		return nil, err
+	}
+	if newAttemptOptions(attemptOpts).validate {
+		if wf == nil {
+			wf, err = c.GetWorkflowWithID(ctx, strconv.FormatInt(body.WorkflowId, 10))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := c.ValidateProjectParams(ctx, wf, params); err != nil {
+			return nil, err
+		}
+	}
+	return c.putAttempt(ctx, body)
+}
+
+// DecodeParams decodes the params of an attempt into P.
+func DecodeParams[P any](a *Attempt) (P, error) {
+	var params P
+	if a.Params == nil {
+		return params, nil
+	}
+	jsn, err := json.Marshal(a.Params)
+	if err != nil {
+		return params, err
+	}
+	err = json.Unmarshal(jsn, &params)
+	return params, err
+}
+
+// UndeclaredParamsError is returned when params are not declared by the workflow.
+type UndeclaredParamsError struct {
+	Workflow string
+	Names    []string
+	// Suggestions maps an undeclared name to a declared name it was probably meant to be.
+	Suggestions map[string]string
+}
+
+func (e *UndeclaredParamsError) Error() string {
+	var names []string
+	for _, name := range e.Names {
+		if s, ok := e.Suggestions[name]; ok {
+			names = append(names, fmt.Sprintf("%s (did you mean %s?)", name, s))
+		} else {
+			names = append(names, name)
+		}
+	}
+	return fmt.Sprintf("workflow %s does not declare params: %s", e.Workflow, strings.Join(names, ", "))
+}
+
+// ValidateParams rejects params which the workflow doesn't declare.
+// A param is declared when it's a key of an _export block or referenced by a ${...} expression
+// anywhere in the workflow definition.
+func ValidateParams(wf *DetailedWorkflow, params interface{}) error {
+	declared := map[string]bool{}
+	collectDeclaredParams(wf.Config, declared)
+	return checkDeclaredParams(wf, params, declared)
+}
+
+// ValidateProjectParams is ValidateParams which also accepts the params declared in the files of the
+// workflow's project revision: _export keys of .dig files, such as the ones call>ed, and ${...}
+// references in any text file, such as the SQL files of td>.
+func (c *Client) ValidateProjectParams(ctx context.Context, wf *DetailedWorkflow, params interface{}) error {
+	archive, err := c.GetProjectArchive(ctx, wf.Project.ID, wf.Revision)
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	collectDeclaredParams(wf.Config, declared)
+	for _, name := range archive.Names() {
+		content := archive.Files[name]
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		collectDeclaredParams(string(content), declared)
+		if !strings.HasSuffix(name, ".dig") {
+			continue
+		}
+		if project, err := dig.Parse(name, content); err == nil {
+			project.Root.Walk(func(t *dig.Task) error {
+				for key := range t.Export {
+					declared[key] = true
+				}
+				return nil
+			})
+		}
+	}
+	return checkDeclaredParams(wf, params, declared)
+}
+
+func checkDeclaredParams(wf *DetailedWorkflow, params interface{}, declared map[string]bool) error {
+	names, err := paramNames(params)
+	if err != nil {
+		return err
+	}
+	var undeclared []string
+	suggestions := map[string]string{}
+	for _, name := range names {
+		if declared[name] {
+			continue
+		}
+		undeclared = append(undeclared, name)
+		if s := closestParam(name, declared); s != "" {
+			suggestions[name] = s
+		}
+	}
+	if len(undeclared) == 0 {
+		return nil
+	}
+	return &UndeclaredParamsError{Workflow: wf.Name, Names: undeclared, Suggestions: suggestions}
+}
+
+func paramsMap(params interface{}) (map[string]interface{}, error) {
+	jsn, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	if string(jsn) == "null" {
+		return nil, nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(jsn, &m); err != nil {
+		return nil, fmt.Errorf("params must encode to a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+func paramNames(params interface{}) ([]string, error) {
+	m, err := paramsMap(params)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+var (
+	expressionPattern    = regexp.MustCompile(`\$\{([^}]*)\}`)
+	stringLiteralPattern = regexp.MustCompile(`'[^']*'|"[^"]*"`)
+	identifierPattern    = regexp.MustCompile(`[A-Za-z_$][A-Za-z0-9_$]*`)
+)
+
+// expressionGlobals are the names of ${...} expressions which aren't params.
+var expressionGlobals = map[string]bool{
+	"moment": true, "JSON": true, "Math": true, "Date": true, "Object": true, "Array": true, "String": true, "Number": true,
+	"parseInt": true, "parseFloat": true, "isNaN": true, "typeof": true,
+	"true": true, "false": true, "null": true, "undefined": true, "NaN": true, "Infinity": true,
+}
+
+func collectDeclaredParams(config interface{}, declared map[string]bool) {
+	switch v := config.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if key == "_export" {
+				if exports, ok := value.(map[string]interface{}); ok {
+					for name := range exports {
+						declared[name] = true
+					}
+				}
+			}
+			collectDeclaredParams(value, declared)
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectDeclaredParams(value, declared)
+		}
+	case string:
+		for _, m := range expressionPattern.FindAllStringSubmatch(v, -1) {
+			expr := stringLiteralPattern.ReplaceAllString(m[1], "''")
+			for _, loc := range identifierPattern.FindAllStringIndex(expr, -1) {
+				if loc[0] > 0 && expr[loc[0]-1] == '.' {
+					continue
+				}
+				// functions, and objects whose members are read, such as td.database, aren't params
+				if next := strings.TrimLeft(expr[loc[1]:], " \t"); strings.HasPrefix(next, "(") || strings.HasPrefix(next, ".") {
+					continue
+				}
+				if name := expr[loc[0]:loc[1]]; !expressionGlobals[name] {
+					declared[name] = true
+				}
+			}
+		}
+	}
+}
+
+// closestParam returns the declared name within an edit distance of two, if any.
+func closestParam(name string, declared map[string]bool) string {
+	best, bestDistance := "", 3
+	for candidate := range declared {
+		d := editDistance(name, candidate)
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
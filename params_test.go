@@ -0,0 +1,186 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type reportParams struct {
+	TargetTable string `json:"target_table"`
+	Limit       int    `json:"limit,omitempty"`
+}
+
+func testWorkflowWithConfig(t *testing.T, config string) *DetailedWorkflow {
+	var cfg interface{}
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &DetailedWorkflow{ID: "100", Name: "report", Config: cfg}
+}
+
+func TestValidateParams(t *testing.T) {
+	wf := testWorkflowWithConfig(t, `{
+		"_export": {"limit": 10},
+		"+load": {"td>": "queries/load.sql", "insert_into": "${target_table}"},
+		"+echo": {"echo>": "${moment(session_time).format('YYYY target_date')} ${JSON.stringify(td)}"}
+	}`)
+
+	tt := []struct {
+		name       string
+		params     interface{}
+		undeclared []string
+	}{
+		{name: "declared by expression and export", params: reportParams{TargetTable: "users", Limit: 5}},
+		{name: "nil params", params: nil},
+		{name: "typo", params: map[string]string{"target_tabel": "users"}, undeclared: []string{"target_tabel"}},
+		{name: "string literal is not a reference", params: map[string]string{"target_date": "x"}, undeclared: []string{"target_date"}},
+		{name: "function is not a reference", params: map[string]string{"moment": "x"}, undeclared: []string{"moment"}},
+		{name: "global is not a reference", params: map[string]string{"JSON": "x"}, undeclared: []string{"JSON"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateParams(wf, tc.params)
+			if tc.undeclared == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			var undeclaredErr *UndeclaredParamsError
+			if !errors.As(err, &undeclaredErr) {
+				t.Fatalf("expected UndeclaredParamsError, got %v", err)
+			}
+			if len(undeclaredErr.Names) != len(tc.undeclared) || undeclaredErr.Names[0] != tc.undeclared[0] {
+				t.Fatalf("undeclared params wrong. want=%v, got=%v", tc.undeclared, undeclaredErr.Names)
+			}
+		})
+	}
+
+	err := ValidateParams(wf, map[string]string{"target_tabel": "users"})
+	if got := err.(*UndeclaredParamsError).Suggestions["target_tabel"]; got != "target_table" {
+		t.Fatalf("suggestion wrong. want=target_table, got=%s", got)
+	}
+	err = ValidateParams(wf, map[string]string{"momnet": "x"})
+	if got, ok := err.(*UndeclaredParamsError).Suggestions["momnet"]; ok {
+		t.Fatalf("functions must not be suggested. got=%s", got)
+	}
+	if err := ValidateParams(wf, []string{"a"}); err == nil {
+		t.Fatal("expected an error for params which are not an object")
+	}
+}
+
+func TestStartAttemptWithParams_Validation(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"report.dig":       "+load:\n  td>: queries/load.sql\n+sub:\n  call>: lib/sub.dig\n",
+		"queries/load.sql": "select * from events where ts < ${cutoff}",
+		"lib/sub.dig":      "_export:\n  limit: 10\n+a:\n  echo>: a\n",
+	})
+	started := 0
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /workflows/100": respondJSON(t, DetailedWorkflow{ID: "100", Name: "report", Project: ProjectInWorkflow{ID: "1"}, Revision: "r1",
+			Config: map[string]interface{}{"+load": map[string]interface{}{"td>": "queries/load.sql"}}}),
+		"GET /projects/1/archive": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("revision") != "r1" {
+				t.Errorf("revision wrong. got=%s", req.URL.Query().Get("revision"))
+			}
+			w.Write(archive)
+		},
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			started++
+			w.Write([]byte(`{"id": "1"}`))
+		},
+	})
+	defer teardown()
+	ctx := context.Background()
+
+	if _, err := StartAttemptWithParams(ctx, client, map[string]interface{}{"cutoff": 1, "limit": 5}, 100, time.Now(), WithParamsValidation()); err != nil {
+		t.Fatalf("params used in SQL and sub-workflows rejected: %s", err)
+	}
+	var undeclaredErr *UndeclaredParamsError
+	_, err := StartAttemptWithParams(ctx, client, map[string]interface{}{"cutof": 1}, 100, time.Now(), WithParamsValidation())
+	if !errors.As(err, &undeclaredErr) || undeclaredErr.Suggestions["cutof"] != "cutoff" {
+		t.Fatalf("expected UndeclaredParamsError, got %v", err)
+	}
+	if _, err := StartAttemptWithParams(ctx, client, map[string]interface{}{"read_by_script": 1}, 100, time.Now()); err != nil {
+		t.Fatalf("params validated without WithParamsValidation: %s", err)
+	}
+	if started != 2 {
+		t.Fatalf("attempts started wrong. got=%d", started)
+	}
+}
+
+func TestStartAttemptWithParams_SessionTimeMode(t *testing.T) {
+	truncated, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00Z")
+	var body AttemptBody
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /workflows/100/truncated_session_time": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("mode") != "day" {
+				t.Errorf("mode wrong. got=%s", req.URL.Query().Get("mode"))
+			}
+			respondJSON(t, WorkflowSessionTime{SessionTime: truncated})(w, req)
+		},
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode attempt: %s", err)
+			}
+			respondJSON(t, Attempt{ID: "1"})(w, req)
+		},
+	})
+	defer teardown()
+
+	params := reportParams{TargetTable: "users"}
+	if _, err := StartAttemptWithParams(context.Background(), client, params, 100, truncated.Add(13*time.Hour), WithSessionTimeMode(SessionTimeDay)); err != nil {
+		t.Fatal(err)
+	}
+	if !body.SessionTime.Equal(truncated) {
+		t.Fatalf("session time wrong. got=%s", body.SessionTime)
+	}
+	if p, ok := body.Params.(map[string]interface{}); !ok || p["target_table"] != "users" {
+		t.Fatalf("params wrong. got=%+v", body.Params)
+	}
+}
+
+func TestStartWorkflowWithParams_SessionTimeMode(t *testing.T) {
+	truncated, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00Z")
+	var body AttemptBody
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects":                   respondJSON(t, Projects{Projects: []Project{{ID: "1", Name: "test"}}}),
+		"GET /projects/1/workflows/daily": respondJSON(t, DetailedWorkflow{ID: "100", Name: "daily"}),
+		"GET /workflows/100/truncated_session_time": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("mode") != "day" {
+				t.Errorf("mode wrong. got=%s", req.URL.Query().Get("mode"))
+			}
+			respondJSON(t, WorkflowSessionTime{SessionTime: truncated})(w, req)
+		},
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode attempt: %s", err)
+			}
+			respondJSON(t, Attempt{ID: "1"})(w, req)
+		},
+	})
+	defer teardown()
+
+	opts := StartWorkflowOptions{Project: "test", Workflow: "daily", SessionTime: truncated.Add(13 * time.Hour)}
+	if _, err := StartWorkflowWithParams(context.Background(), client, opts, reportParams{TargetTable: "users"}, WithSessionTimeMode(SessionTimeDay)); err != nil {
+		t.Fatal(err)
+	}
+	if !body.SessionTime.Equal(truncated) {
+		t.Fatalf("session time wrong. got=%s", body.SessionTime)
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	attempt := &Attempt{Params: map[string]interface{}{"target_table": "users", "limit": float64(3), "_td_.revision_created_user_id": float64(1)}}
+	params, err := DecodeParams[reportParams](attempt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.TargetTable != "users" || params.Limit != 3 {
+		t.Fatalf("params wrong. got=%+v", params)
+	}
+}
@@ -3,7 +3,6 @@ package digdaggo
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -100,7 +99,9 @@ func (c *Client) GetProjectsWithID(ctx context.Context, projectId string) (*Proj
 	return &project, nil
 }
 
-func (c *Client) PutProject(ctx context.Context, filepath, projectName string) (*Project, error) {
+// PutProject uploads a gzipped tar archive as a new revision of the project.
+// Options can inspect the archive before anything is sent.
+func (c *Client) PutProject(ctx context.Context, filepath, projectName string, opts ...PushOption) (*Project, error) {
 	if projectName == "" {
 		return nil, errors.New("project name is required")
 	}
@@ -111,20 +112,18 @@ func (c *Client) PutProject(ctx context.Context, filepath, projectName string) (
 	parameters["project"] = projectName
 	parameters["revision"] = revision
 
-	digFiles, err := os.Open(filepath)
+	digFiles, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	defer func(digFiles *os.File) {
-		err := digFiles.Close()
-		if err != nil {
-
-		}
-	}(digFiles)
+	// the checks see exactly the bytes which are uploaded
+	if err := c.checkPush(digFiles, opts); err != nil {
+		return nil, err
+	}
 
 	header := map[string]string{"content-type": "application/gzip"}
 
-	req, err := c.newRequest(ctx, "PUT", "projects", parameters, digFiles, header)
+	req, err := c.newRequest(ctx, "PUT", "projects", parameters, bytes.NewReader(digFiles), header)
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +146,7 @@ func (c *Client) PutProject(ctx context.Context, filepath, projectName string) (
 	if err != nil {
 		return nil, err
 	}
+	c.workflows.forgetProject(projectName)
 	return &project, nil
 }
 
@@ -288,87 +288,6 @@ func (c *Client) GetProjectsSchedules(ctx context.Context, projectId, workflow,
 	return &schedules, nil
 }
 
-type Secrets struct {
-	Secrets []interface{} `json:"secrets"`
-}
-
-func (c *Client) GetSecrets(ctx context.Context, projectId string) (*Secrets, error) {
-	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("projects/%s/secrets", projectId), nil, nil, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-
-		}
-	}(resp.Body)
-	checkStatus := c.checkHttpResponseCode(resp)
-	if checkStatus != nil {
-		return nil, checkStatus
-	}
-	var secrets Secrets
-	err = c.decodeBody(resp, &secrets)
-	if err != nil {
-		return nil, err
-	}
-	return &secrets, nil
-
-}
-
-func (c *Client) PutSecrets(ctx context.Context, projectId string, secrets map[string]string) error {
-	jsn, err := json.Marshal(secrets)
-	if err != nil {
-		return err
-	}
-	req, err := c.newRequest(ctx, "PUT", fmt.Sprintf("projects/%s/secrets", projectId), nil, bytes.NewReader(jsn), nil)
-	if err != nil {
-		return err
-	}
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-
-		}
-	}(resp.Body)
-
-	checkStatus := c.checkHttpResponseCode(resp)
-	if checkStatus != nil {
-		return checkStatus
-	}
-	return nil
-}
-
-func (c *Client) DeleteSecret(ctx context.Context, projectId, key string) error {
-	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("projects/%s/secrets/%s", projectId, key), nil, nil, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-
-		}
-	}(resp.Body)
-	checkStatus := c.checkHttpResponseCode(resp)
-	if checkStatus != nil {
-		return checkStatus
-	}
-	return nil
-}
-
 // Sessions List of the Sessions
 type Sessions struct {
 	Sessions []Session `json:"sessions"`
@@ -463,7 +382,7 @@ type ProjectWorkflow struct {
 func (c *Client) GetProjectWorkflows(ctx context.Context, projectId, revision, workflowName string) (*Workflows, error) {
 	parameters := map[string]string{}
 	if revision != "" {
-		parameters["workflow"] = revision
+		parameters["revision"] = revision
 	}
 	if workflowName != "" {
 		parameters["name"] = workflowName
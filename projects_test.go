@@ -123,10 +123,14 @@ func TestClient_GetProjectsWithID(t *testing.T) {
 func setup(t *testing.T, expectedRes interface{}, expectedMethod, expectedRequestPath string) (*Client, func()) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req.Method != expectedMethod {
-			t.Fatalf("request method wrong. want=%s, got=%s", expectedMethod, req.Method)
+			t.Errorf("request method wrong. want=%s, got=%s", expectedMethod, req.Method)
+			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
 		if req.URL.Path != expectedRequestPath {
-			t.Fatalf("request path wrong. want=%s, got=%s", expectedRequestPath, req.URL.Path)
+			t.Errorf("request path wrong. want=%s, got=%s", expectedRequestPath, req.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
 
 		res, err := json.Marshal(expectedRes)
@@ -0,0 +1,100 @@
+package digdaggo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"digdagGo/dig"
+)
+
+// PushOption configures the checks PutProject runs on an archive before uploading it.
+type PushOption func(*pushOptions)
+
+type pushOptions struct {
+	scanLeaks  bool
+	leakRules  []LeakRule
+	allowLeaks bool
+	validate   bool
+	checkOpts  dig.CheckOptions
+}
+
+// WithValidation refuses the push with a *ValidationError when dig.Check finds problems in the archive.
+func WithValidation(opts dig.CheckOptions) PushOption {
+	return func(o *pushOptions) {
+		o.validate = true
+		o.checkOpts = opts
+	}
+}
+
+// ValidationError is returned by PutProject when the validation finds problems.
+type ValidationError struct {
+	Diagnostics []dig.Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return fmt.Sprintf("refusing to push, %d problems found:\n%s", len(e.Diagnostics), strings.Join(lines, "\n"))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidProject
+}
+
+// WithLeakScan refuses the push with a *LeakError when the archive contains hard-coded credentials.
+// DefaultLeakRules are used when no rules are given.
+func WithLeakScan(rules ...LeakRule) PushOption {
+	return func(o *pushOptions) {
+		o.scanLeaks = true
+		if len(rules) > 0 {
+			o.leakRules = rules
+		} else {
+			o.leakRules = DefaultLeakRules
+		}
+	}
+}
+
+// AllowLeaks overrides WithLeakScan: findings are logged and the push goes ahead.
+func AllowLeaks() PushOption {
+	return func(o *pushOptions) {
+		o.allowLeaks = true
+	}
+}
+
+func (c *Client) checkPush(data []byte, opts []PushOption) error {
+	var o pushOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.scanLeaks && !o.validate {
+		return nil
+	}
+	archive, err := ReadProjectArchive(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if o.validate {
+		if diagnostics := dig.Check(archive.FS(), o.checkOpts); len(diagnostics) > 0 {
+			return &ValidationError{Diagnostics: diagnostics}
+		}
+	}
+	if !o.scanLeaks {
+		return nil
+	}
+	findings := ScanForLeaks(archive, o.leakRules)
+	if len(findings) == 0 {
+		return nil
+	}
+	if !o.allowLeaks {
+		return &LeakError{Findings: findings}
+	}
+	if c.Logger != nil {
+		for _, f := range findings {
+			c.Logger.Printf("pushing despite possible credential: %s", f)
+		}
+	}
+	return nil
+}
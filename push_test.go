@@ -0,0 +1,58 @@
+package digdaggo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"digdagGo/dig"
+)
+
+func TestPutProjectValidation(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.tar.gz")
+	valid := filepath.Join(dir, "valid.tar.gz")
+	files := map[string]string{
+		"daily.dig":     "+a:\n  td>: queries/a.sql\n+b:\n  custom>: b\n",
+		"queries/a.sql": "select 1",
+	}
+	if err := os.WriteFile(invalid, buildArchive(t, map[string]string{"daily.dig": files["daily.dig"]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(valid, buildArchive(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pushed := 0
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"PUT /projects": func(w http.ResponseWriter, req *http.Request) {
+			pushed++
+			respondJSON(t, Project{ID: "1", Name: "test"})(w, req)
+		},
+	})
+	defer teardown()
+
+	opts := dig.CheckOptions{Operators: []string{"custom"}}
+	_, err := client.PutProject(context.Background(), invalid, "test", WithValidation(opts))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrInvalidProject) {
+		t.Fatalf("expected a validation error. got=%v", err)
+	}
+	want := dig.Diagnostic{File: "daily.dig", Line: 2, Message: "SQL file queries/a.sql does not exist"}
+	if len(validationErr.Diagnostics) != 1 || validationErr.Diagnostics[0] != want {
+		t.Fatalf("diagnostics wrong. got=%v", validationErr.Diagnostics)
+	}
+	if pushed != 0 {
+		t.Fatal("invalid project was uploaded")
+	}
+
+	if _, err := client.PutProject(context.Background(), valid, "test", WithValidation(opts), WithLeakScan()); err != nil {
+		t.Fatal(err)
+	}
+	if pushed != 1 {
+		t.Fatalf("pushes wrong. got=%d", pushed)
+	}
+}
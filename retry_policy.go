@@ -0,0 +1,364 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RetryRule classifies a failed task as transient. Every pattern which is set must match.
+// A rule without Error and Log patterns never matches.
+type RetryRule struct {
+	Name string
+	// Task matches the full name of the failed task. Any task matches when nil.
+	Task *regexp.Regexp
+	// Error matches the error message of the failed task.
+	Error *regexp.Regexp
+	// Log matches any line of the log of the failed task.
+	Log *regexp.Regexp
+}
+
+// RetryPolicy configures a RetryEngine.
+type RetryPolicy struct {
+	// Project and Workflow restrict the attempts which are watched. Every attempt is watched when empty.
+	Project  string
+	Workflow string
+	// Rules are tried in order; the first matching rule fires.
+	Rules []RetryRule
+	// MaxRetries is the number of retries per session. Retry requests which failed count too.
+	MaxRetries int
+	// Backoff is the wait before the first retry. It doubles on every further retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAge ignores attempts which finished longer ago. Defaults to 24 hours.
+	MaxAge time.Duration
+	// PollInterval is the interval of Run. Defaults to one minute.
+	PollInterval time.Duration
+	// Audit receives every decision as a JSON line. Decisions go to the client's Logger when nil.
+	Audit io.Writer
+}
+
+// RetryDecision is what a RetryEngine decided for a failed attempt.
+type RetryDecision string
+
+const (
+	DecisionRetry       RetryDecision = "retry"
+	DecisionWait        RetryDecision = "wait_backoff"
+	DecisionNoRule      RetryDecision = "skip_no_rule"
+	DecisionLimit       RetryDecision = "skip_limit"
+	DecisionRetryFailed RetryDecision = "retry_failed"
+	DecisionError       RetryDecision = "error"
+)
+
+// AuditEntry records a decision of a RetryEngine.
+type AuditEntry struct {
+	Time           time.Time     `json:"time"`
+	AttemptID      string        `json:"attemptId"`
+	SessionID      string        `json:"sessionId"`
+	Project        string        `json:"project"`
+	Workflow       string        `json:"workflow"`
+	Decision       RetryDecision `json:"decision"`
+	Rule           string        `json:"rule,omitempty"`
+	Task           string        `json:"task,omitempty"`
+	Retries        int           `json:"retries"`
+	RetryAt        *time.Time    `json:"retryAt,omitempty"`
+	RetryAttemptID string        `json:"retryAttemptId,omitempty"`
+	Reason         string        `json:"reason,omitempty"`
+}
+
+// RetryEngine watches attempts and retries the failed ones from their failed tasks when a rule classifies
+// the failure as transient.
+type RetryEngine struct {
+	client *Client
+	policy RetryPolicy
+	logger *log.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	decided map[string]decidedAttempt
+}
+
+// decidedAttempt is the last decision for an attempt, kept until the attempt is older than MaxAge.
+// failedRequests counts the retry requests for the attempt which failed, the last one at lastRequest.
+type decidedAttempt struct {
+	decision       RetryDecision
+	finishedAt     time.Time
+	failedRequests int
+	lastRequest    time.Time
+}
+
+func NewRetryEngine(client *Client, policy RetryPolicy) (*RetryEngine, error) {
+	if len(policy.Rules) == 0 {
+		return nil, errors.New("retry policy must have at least one rule")
+	}
+	if policy.MaxRetries <= 0 {
+		return nil, errors.New("max retries must be positive")
+	}
+	for _, rule := range policy.Rules {
+		if rule.Name == "" {
+			return nil, errors.New("retry rule name must not be empty")
+		}
+	}
+	if policy.MaxAge == 0 {
+		policy.MaxAge = 24 * time.Hour
+	}
+	if policy.PollInterval == 0 {
+		policy.PollInterval = time.Minute
+	}
+	logger := client.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &RetryEngine{
+		client:  client,
+		policy:  policy,
+		logger:  logger,
+		now:     time.Now,
+		decided: map[string]decidedAttempt{},
+	}, nil
+}
+
+// Run polls until the context is canceled.
+func (e *RetryEngine) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.policy.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.Poll(ctx); err != nil {
+			e.logger.Printf("retry engine: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll makes one pass over the latest attempt of every session and returns the decisions it made.
+// A decision is made once per attempt, except that waits for the backoff, failed retry requests and attempts
+// which couldn't be inspected are revisited on the next pass. An error inspecting one attempt is recorded in
+// its entry and doesn't stop the pass.
+func (e *RetryEngine) Poll(ctx context.Context) ([]AuditEntry, error) {
+	cutoff := e.now().Add(-e.policy.MaxAge)
+	e.forget(cutoff)
+	var failed []Attempt
+	err := e.client.forEachAttempt(ctx, e.policy.Project, e.policy.Workflow, false, func(a *Attempt) (bool, error) {
+		if a.CreatedAt.Before(cutoff) {
+			return false, nil
+		}
+		if a.Done && !a.Success && !a.CancelRequested && a.FinishedAt.After(cutoff) {
+			failed = append(failed, *a)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for i := range failed {
+		previous := e.lookup(failed[i].ID)
+		if isDecided(previous.decision) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return entries, err
+		}
+		entry := e.decide(ctx, &failed[i], previous)
+		if entry.Decision != previous.decision || entry.Decision != DecisionWait && entry.Decision != DecisionError {
+			e.audit(entry)
+		}
+		next := previous
+		next.decision = entry.Decision
+		next.finishedAt = failed[i].FinishedAt
+		if entry.Decision == DecisionRetryFailed {
+			next.failedRequests++
+			next.lastRequest = entry.Time
+		}
+		e.store(failed[i].ID, next)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// decide makes the decision for a failed attempt. Failed retry requests from previous passes count as
+// retries, and the backoff starts from the last of them. Errors are recorded as DecisionError.
+func (e *RetryEngine) decide(ctx context.Context, a *Attempt, previous decidedAttempt) AuditEntry {
+	entry := AuditEntry{
+		Time:      e.now(),
+		AttemptID: a.ID,
+		SessionID: a.SessionID,
+		Project:   a.Project.Name,
+		Workflow:  a.Workflow.Name,
+	}
+
+	rule, task, err := e.classify(ctx, a)
+	if err != nil {
+		entry.Decision = DecisionError
+		entry.Reason = err.Error()
+		return entry
+	}
+	if rule == nil {
+		entry.Decision = DecisionNoRule
+		entry.Reason = "no rule matched the failed tasks"
+		return entry
+	}
+	entry.Rule = rule.Name
+	entry.Task = task
+
+	attempts, err := e.client.GetAllSessionAttempts(ctx, a.SessionID)
+	if err != nil {
+		entry.Decision = DecisionError
+		entry.Reason = err.Error()
+		return entry
+	}
+	sessionRetries := len(attempts.Attempts) - 1
+	if sessionRetries < 0 {
+		sessionRetries = 0
+	}
+	entry.Retries = sessionRetries + previous.failedRequests
+	if entry.Retries >= e.policy.MaxRetries {
+		entry.Decision = DecisionLimit
+		entry.Reason = fmt.Sprintf("session was already retried %d times", sessionRetries)
+		if previous.failedRequests > 0 {
+			entry.Reason += fmt.Sprintf(" and %d retry requests failed", previous.failedRequests)
+		}
+		return entry
+	}
+
+	since := a.FinishedAt
+	if previous.lastRequest.After(since) {
+		since = previous.lastRequest
+	}
+	retryAt := since.Add(e.backoff(entry.Retries))
+	entry.RetryAt = &retryAt
+	if e.now().Before(retryAt) {
+		entry.Decision = DecisionWait
+		return entry
+	}
+
+	retry, err := e.client.RetryAttempt(ctx, a.ID, RetryOptions{Mode: RetryFailed})
+	if err != nil {
+		entry.Decision = DecisionRetryFailed
+		entry.Reason = err.Error()
+		return entry
+	}
+	entry.Decision = DecisionRetry
+	entry.RetryAttemptID = retry.ID
+	return entry
+}
+
+// classify returns the first rule matching a failed task of the attempt, and the task's name.
+func (e *RetryEngine) classify(ctx context.Context, a *Attempt) (*RetryRule, string, error) {
+	tasks, err := e.client.ListTasks(ctx, a.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	logs := map[string][]string{}
+	for _, rule := range e.policy.Rules {
+		rule := rule
+		if rule.Error == nil && rule.Log == nil {
+			continue
+		}
+		for _, task := range tasks.Tasks {
+			if task.IsGroup || task.State != "error" {
+				continue
+			}
+			if rule.Task != nil && !rule.Task.MatchString(task.FullName) {
+				continue
+			}
+			if rule.Error != nil && !rule.Error.MatchString(task.Error.Message) {
+				continue
+			}
+			if rule.Log != nil {
+				lines, ok := logs[task.FullName]
+				if !ok {
+					lines, err = e.client.GetTaskLogLines(ctx, a.ID, task.FullName)
+					if err != nil {
+						return nil, "", err
+					}
+					logs[task.FullName] = lines
+				}
+				if !anyLineMatches(rule.Log, lines) {
+					continue
+				}
+			}
+			return &rule, task.FullName, nil
+		}
+	}
+	return nil, "", nil
+}
+
+func anyLineMatches(re *regexp.Regexp, lines []string) bool {
+	for _, line := range lines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *RetryEngine) backoff(retries int) time.Duration {
+	d := e.policy.Backoff
+	for i := 0; i < retries; i++ {
+		d *= 2
+		if e.policy.MaxBackoff > 0 && d >= e.policy.MaxBackoff {
+			return e.policy.MaxBackoff
+		}
+	}
+	return d
+}
+
+func (e *RetryEngine) audit(entry AuditEntry) {
+	jsn, err := json.Marshal(entry)
+	if err != nil {
+		e.logger.Printf("retry engine: %s", err)
+		return
+	}
+	if e.policy.Audit == nil {
+		e.logger.Printf("retry engine: %s", jsn)
+		return
+	}
+	if _, err := e.policy.Audit.Write(append(jsn, '\n')); err != nil {
+		e.logger.Printf("retry engine: %s", err)
+	}
+}
+
+// isDecided reports whether an attempt with the decision needs no further decision.
+// Attempts waiting for the backoff, whose retry request failed or which couldn't be inspected are revisited.
+func isDecided(d RetryDecision) bool {
+	switch d {
+	case "", DecisionWait, DecisionRetryFailed, DecisionError:
+		return false
+	}
+	return true
+}
+
+func (e *RetryEngine) lookup(attemptID string) decidedAttempt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.decided[attemptID]
+}
+
+func (e *RetryEngine) store(attemptID string, d decidedAttempt) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.decided[attemptID] = d
+}
+
+// forget drops the decisions for attempts which finished before the cutoff. Poll doesn't see them again.
+func (e *RetryEngine) forget(cutoff time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, d := range e.decided {
+		if d.finishedAt.Before(cutoff) {
+			delete(e.decided, id)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRetryEngine_Poll(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2022-04-01T12:00:00Z")
+	failedAttempt := func(id, session string) Attempt {
+		return Attempt{
+			ID:          id,
+			SessionID:   session,
+			Project:     ProjectInAttempt{ID: "1", Name: "test"},
+			Workflow:    WorkflowInAttempt{ID: "100", Name: "daily"},
+			Done:        true,
+			Status:      "error",
+			Params:      map[string]interface{}{},
+			CreatedAt:   now.Add(-time.Hour),
+			FinishedAt:  now.Add(-30 * time.Minute),
+			SessionTime: now.Add(-2 * time.Hour),
+		}
+	}
+	timeout := failedAttempt("10", "s1")
+	syntax := failedAttempt("20", "s2")
+	exhausted := failedAttempt("30", "s3")
+	tasks := map[string]string{
+		"10": `{"tasks": [{"fullName": "+daily", "state": "group_error", "isGroup": true}, {"fullName": "+daily+load", "state": "error", "error": {"message": "Query timed out"}}]}`,
+		"20": `{"tasks": [{"fullName": "+daily+load", "state": "error", "error": {"message": "Syntax error at line 3"}}]}`,
+		"30": `{"tasks": [{"fullName": "+daily+load", "state": "error", "error": {"message": "Query timed out"}}]}`,
+	}
+	retries := 0
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{timeout, syntax, exhausted}})(w, req)
+		},
+		"GET /attempts/10/tasks": func(w http.ResponseWriter, req *http.Request) { w.Write([]byte(tasks["10"])) },
+		"GET /attempts/20/tasks": func(w http.ResponseWriter, req *http.Request) { w.Write([]byte(tasks["20"])) },
+		"GET /attempts/30/tasks": func(w http.ResponseWriter, req *http.Request) { w.Write([]byte(tasks["30"])) },
+		"GET /sessions/s1/attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{timeout}})(w, req)
+		},
+		"GET /sessions/s3/attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{exhausted, {ID: "29"}, {ID: "28"}}})(w, req)
+		},
+		"GET /attempts/10": respondJSON(t, timeout),
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			retries++
+			var body RetryAttemptBody
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if body.Resume == nil || body.Resume.Mode != RetryFailed || body.Resume.AttemptId != "10" {
+				t.Errorf("retry must resume attempt 10 from failed tasks. got=%+v", body.Resume)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, Attempt{ID: "11"})(w, req)
+		},
+	})
+	defer teardown()
+
+	var audit bytes.Buffer
+	engine, err := NewRetryEngine(client, RetryPolicy{
+		Rules: []RetryRule{
+			{Name: "warehouse-timeout", Error: regexp.MustCompile(`(?i)timed out`)},
+		},
+		MaxRetries: 2,
+		Backoff:    10 * time.Minute,
+		Audit:      &audit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.now = func() time.Time { return now }
+
+	entries, err := engine.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decisions := map[string]AuditEntry{}
+	for _, entry := range entries {
+		decisions[entry.AttemptID] = entry
+	}
+	if d := decisions["10"]; d.Decision != DecisionRetry || d.Rule != "warehouse-timeout" || d.Task != "+daily+load" || d.RetryAttemptID != "11" {
+		t.Fatalf("decision for attempt 10 wrong. got=%+v", d)
+	}
+	if d := decisions["20"]; d.Decision != DecisionNoRule {
+		t.Fatalf("decision for attempt 20 wrong. got=%+v", d)
+	}
+	if d := decisions["30"]; d.Decision != DecisionLimit || d.Retries != 2 {
+		t.Fatalf("decision for attempt 30 wrong. got=%+v", d)
+	}
+	if lines := bytes.Count(audit.Bytes(), []byte("\n")); lines != 3 {
+		t.Fatalf("audit log must have a line per decision. got=%q", audit.String())
+	}
+
+	entries, err = engine.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 || retries != 1 {
+		t.Fatalf("attempts were decided twice: %+v", entries)
+	}
+
+	engine.now = func() time.Time { return now.Add(24 * time.Hour) }
+	if entries, err := engine.Poll(context.Background()); err != nil || len(entries) != 0 {
+		t.Fatalf("attempts older than MaxAge were decided: %+v, %v", entries, err)
+	}
+	if len(engine.decided) != 0 {
+		t.Fatalf("decisions for attempts older than MaxAge were kept: %v", engine.decided)
+	}
+}
+
+func TestRetryEngine_Poll_failedRequests(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2022-04-01T12:00:00Z")
+	failed := Attempt{
+		ID:         "10",
+		SessionID:  "s1",
+		Project:    ProjectInAttempt{ID: "1", Name: "test"},
+		Workflow:   WorkflowInAttempt{ID: "100", Name: "daily"},
+		Done:       true,
+		Status:     "error",
+		CreatedAt:  now.Add(-time.Hour),
+		FinishedAt: now.Add(-30 * time.Minute),
+	}
+	requests := 0
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{failed}})(w, req)
+		},
+		"GET /attempts/10/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": [{"fullName": "+daily+load", "state": "error", "error": {"message": "Query timed out"}}]}`))
+		},
+		"GET /sessions/s1/attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{failed}})(w, req)
+		},
+		"GET /attempts/10": respondJSON(t, failed),
+		"PUT /attempts": func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	})
+	defer teardown()
+
+	engine, err := NewRetryEngine(client, RetryPolicy{
+		Rules:      []RetryRule{{Name: "timeout", Error: regexp.MustCompile(`timed out`)}},
+		MaxRetries: 2,
+		Backoff:    10 * time.Minute,
+		Audit:      &bytes.Buffer{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll := func(at time.Time) RetryDecision {
+		t.Helper()
+		engine.now = func() time.Time { return at }
+		entries, err := engine.Poll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 {
+			return ""
+		}
+		return entries[0].Decision
+	}
+
+	if d := poll(now); d != DecisionRetryFailed {
+		t.Fatalf("first decision wrong. got=%s", d)
+	}
+	if d := poll(now); d != DecisionWait {
+		t.Fatalf("a failed request must back off. got=%s", d)
+	}
+	if d := poll(now.Add(20 * time.Minute)); d != DecisionRetryFailed {
+		t.Fatalf("decision after the backoff wrong. got=%s", d)
+	}
+	if d := poll(now.Add(time.Hour)); d != DecisionLimit {
+		t.Fatalf("failed requests must count towards MaxRetries. got=%s", d)
+	}
+	if d := poll(now.Add(2 * time.Hour)); d != "" || requests != 2 {
+		t.Fatalf("retry requests wrong. decision=%s, requests=%d", d, requests)
+	}
+}
+
+func TestRetryEngine_Poll_attemptError(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2022-04-01T12:00:00Z")
+	failedAttempt := func(id string) Attempt {
+		return Attempt{ID: id, SessionID: "s" + id, Done: true, Status: "error", CreatedAt: now.Add(-time.Hour), FinishedAt: now.Add(-30 * time.Minute)}
+	}
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: []Attempt{failedAttempt("20"), failedAttempt("10")}})(w, req)
+		},
+		"GET /attempts/20/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"GET /attempts/10/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": [{"fullName": "+daily+load", "state": "error", "error": {"message": "Syntax error"}}]}`))
+		},
+	})
+	defer teardown()
+
+	var audit bytes.Buffer
+	engine, err := NewRetryEngine(client, RetryPolicy{
+		Rules:      []RetryRule{{Name: "timeout", Error: regexp.MustCompile(`timed out`)}},
+		MaxRetries: 2,
+		Audit:      &audit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.now = func() time.Time { return now }
+
+	entries, err := engine.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Decision != DecisionError || entries[0].Reason == "" || entries[1].Decision != DecisionNoRule {
+		t.Fatalf("an attempt which can't be inspected must not stop the pass. got=%+v", entries)
+	}
+	if lines := bytes.Count(audit.Bytes(), []byte("\n")); lines != 2 {
+		t.Fatalf("audit log must have a line per decision. got=%q", audit.String())
+	}
+	entries, err = engine.Poll(context.Background())
+	if err != nil || len(entries) != 1 || entries[0].AttemptID != "20" {
+		t.Fatalf("attempts which couldn't be inspected must be revisited. got=%+v, %v", entries, err)
+	}
+}
+
+func TestRetryEngine_backoff(t *testing.T) {
+	engine := &RetryEngine{policy: RetryPolicy{Backoff: time.Minute, MaxBackoff: 5 * time.Minute}}
+	for retries, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		if got := engine.backoff(retries); got != want {
+			t.Fatalf("backoff after %d retries wrong. want=%s, got=%s", retries, want, got)
+		}
+	}
+}
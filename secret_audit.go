@@ -0,0 +1,131 @@
+package digdaggo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"path"
+	"regexp"
+	"sort"
+)
+
+var secretReferencePattern = regexp.MustCompile(`\$\{\s*secret:([^}\s]+)\s*\}`)
+
+// SecretReference is a ${secret:...} reference in a project file.
+type SecretReference struct {
+	Key  string `json:"key"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// SecretAuditOptions configures the secret audit.
+type SecretAuditOptions struct {
+	// Revision audits the given revision instead of the latest one.
+	Revision string
+	// Ignore holds path.Match patterns of keys which are never reported as unused, such as secrets
+	// which operators read implicitly, e.g. "td.apikey" or "aws.*".
+	Ignore []string
+}
+
+// SecretAuditReport compares the secret references of a project revision with the project's secrets.
+type SecretAuditReport struct {
+	ProjectID   string `json:"projectId"`
+	ProjectName string `json:"projectName"`
+	Revision    string `json:"revision"`
+	// Missing are the references to keys which are not set on the project.
+	Missing []SecretReference `json:"missing"`
+	// Unused are the keys which are set but never referenced.
+	Unused []string `json:"unused"`
+	// References are all references found, by file and line.
+	References []SecretReference `json:"references"`
+}
+
+// ScanSecretReferences finds the ${secret:...} references in the text files of an archive.
+func ScanSecretReferences(archive *ProjectArchive) []SecretReference {
+	var refs []SecretReference
+	for _, name := range archive.Names() {
+		content := archive.Files[name]
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			for _, m := range secretReferencePattern.FindAllStringSubmatch(scanner.Text(), -1) {
+				refs = append(refs, SecretReference{Key: m[1], File: name, Line: line})
+			}
+		}
+	}
+	return refs
+}
+
+// AuditProjectSecrets scans the files of a project revision for ${secret:...} references and reports
+// references to keys which are not set, and keys which are set but never referenced.
+func (c *Client) AuditProjectSecrets(ctx context.Context, projectId string, opts SecretAuditOptions) (*SecretAuditReport, error) {
+	project, err := c.GetProjectsWithID(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+	revision := opts.Revision
+	if revision == "" {
+		revision = project.Revision
+	}
+	archive, err := c.GetProjectArchive(ctx, projectId, revision)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := c.GetSecrets(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SecretAuditReport{ProjectID: project.ID, ProjectName: project.Name, Revision: revision}
+	report.References = ScanSecretReferences(archive)
+	set := map[string]bool{}
+	for _, key := range secrets.Keys() {
+		set[key] = true
+	}
+	referenced := map[string]bool{}
+	for _, ref := range report.References {
+		referenced[ref.Key] = true
+		if !set[ref.Key] {
+			report.Missing = append(report.Missing, ref)
+		}
+	}
+	for _, key := range secrets.Keys() {
+		if !referenced[key] && !matchesAny(key, opts.Ignore) {
+			report.Unused = append(report.Unused, key)
+		}
+	}
+	return report, nil
+}
+
+// AuditAllProjectSecrets audits the latest revision of every project, in project name order.
+func (c *Client) AuditAllProjectSecrets(ctx context.Context, opts SecretAuditOptions) ([]SecretAuditReport, error) {
+	projects, err := c.GetProjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(projects.Projects, func(i, j int) bool { return projects.Projects[i].Name < projects.Projects[j].Name })
+	opts.Revision = ""
+	var reports []SecretAuditReport
+	for _, project := range projects.Projects {
+		report, err := c.AuditProjectSecrets(ctx, project.ID, opts)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+func matchesAny(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
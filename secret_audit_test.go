@@ -0,0 +1,45 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestClient_AuditProjectSecrets(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"daily.dig":       "_env:\n  DB_PASSWORD: ${secret:db.password}\n+load:\n  sh>: scripts/load.sh\n",
+		"scripts/load.sh": "curl -u user:${ secret:api.token } https://example.com\n",
+		"queries/q.sql":   "select '${secret:missing.key}'",
+		"blob.bin":        "\x00${secret:in.binary}",
+	})
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects/1": respondJSON(t, Project{ID: "1", Name: "test", Revision: "r1"}),
+		"GET /projects/1/archive": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("revision") != "r1" {
+				t.Errorf("revision wrong. got=%s", req.URL.Query().Get("revision"))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write(archive)
+		},
+		"GET /projects/1/secrets": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"secrets": [{"key": "db.password"}, {"key": "api.token"}, {"key": "td.apikey"}, {"key": "stale.key"}]}`))
+		},
+	})
+	defer teardown()
+
+	report, err := client.AuditProjectSecrets(context.Background(), "1", SecretAuditOptions{Ignore: []string{"td.*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ProjectName != "test" || report.Revision != "r1" || len(report.References) != 3 {
+		t.Fatalf("report wrong. got=%+v", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != (SecretReference{Key: "missing.key", File: "queries/q.sql", Line: 1}) {
+		t.Fatalf("missing wrong. got=%+v", report.Missing)
+	}
+	if len(report.Unused) != 1 || report.Unused[0] != "stale.key" {
+		t.Fatalf("unused wrong. got=%v", report.Unused)
+	}
+}
@@ -0,0 +1,213 @@
+package digdaggo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SecretsFromDotenv reads secrets from a dotenv file: KEY=VALUE lines with optional "export " prefixes,
+// single- or double-quoted values, and # comments. Errors never contain values.
+func SecretsFromDotenv(r io.Reader) (map[string]string, error) {
+	secrets := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("dotenv line %d: expected KEY=VALUE", lineNo)
+		}
+		value, err := dotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("dotenv line %d (%s): %w", lineNo, key, err)
+		}
+		secrets[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func dotenvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			ch := raw[i]
+			switch {
+			case ch == '"':
+				return b.String(), nil
+			case ch == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	}
+	// unquoted values end at an inline comment
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return raw, nil
+}
+
+// SecretsFromEnv reads secrets from the environment variables starting with prefix.
+// The key is the rest of the variable name in lower case with "__" standing for ".",
+// so TD_SECRET_TD__APIKEY becomes td.apikey with the prefix "TD_SECRET_".
+func SecretsFromEnv(prefix string) map[string]string {
+	secrets := map[string]string{}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if prefix == "" || !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, prefix), "__", "."))
+		secrets[key] = value
+	}
+	return secrets
+}
+
+// SecretSyncOptions controls SyncSecrets.
+type SecretSyncOptions struct {
+	// DryRun only plans the changes.
+	DryRun bool
+	// NoDelete keeps secrets which are not in the desired set.
+	NoDelete bool
+}
+
+// SecretAction is what SyncSecrets does with a secret key.
+type SecretAction string
+
+const (
+	SecretCreate SecretAction = "create"
+	// SecretUpdate overwrites an existing secret. Values can't be read back, so every desired secret
+	// which already exists is updated.
+	SecretUpdate SecretAction = "update"
+	SecretDelete SecretAction = "delete"
+	// SecretKeep leaves an undesired secret in place because of NoDelete.
+	SecretKeep SecretAction = "keep"
+)
+
+// SecretChange is a planned or applied change of one secret. It never holds the value.
+type SecretChange struct {
+	Key    string       `json:"key"`
+	Action SecretAction `json:"action"`
+	Err    error        `json:"-"`
+}
+
+// SecretPlan lists the changes of SyncSecrets in key order.
+type SecretPlan struct {
+	ProjectID string         `json:"projectId"`
+	DryRun    bool           `json:"dryRun"`
+	Changes   []SecretChange `json:"changes"`
+}
+
+// String renders the plan one change per line, without values.
+func (p *SecretPlan) String() string {
+	var b strings.Builder
+	for _, change := range p.Changes {
+		fmt.Fprintf(&b, "%-6s %s", change.Action, change.Key)
+		if change.Err != nil {
+			fmt.Fprintf(&b, " (failed: %s)", change.Err)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// SyncSecrets converges the secrets of a project to the desired set: missing keys are created, existing
+// ones are overwritten, and the others are deleted unless NoDelete is set. Every key and value is validated
+// before anything is changed. When some changes fail, the rest are still applied and an error is returned
+// along with the plan, whose changes carry their errors.
+func (c *Client) SyncSecrets(ctx context.Context, projectId string, desired map[string]string, opts SecretSyncOptions) (*SecretPlan, error) {
+	for key, value := range desired {
+		if err := ValidateSecretKey(key); err != nil {
+			return nil, err
+		}
+		if err := ValidateSecretValue(value); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", key, err)
+		}
+	}
+	current, err := c.GetSecrets(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, key := range current.Keys() {
+		existing[key] = true
+	}
+
+	plan := &SecretPlan{ProjectID: projectId, DryRun: opts.DryRun}
+	for key := range desired {
+		action := SecretCreate
+		if existing[key] {
+			action = SecretUpdate
+		}
+		plan.Changes = append(plan.Changes, SecretChange{Key: key, Action: action})
+	}
+	for key := range existing {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		action := SecretDelete
+		if opts.NoDelete {
+			action = SecretKeep
+		}
+		plan.Changes = append(plan.Changes, SecretChange{Key: key, Action: action})
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool { return plan.Changes[i].Key < plan.Changes[j].Key })
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	failed := 0
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		switch change.Action {
+		case SecretCreate, SecretUpdate:
+			change.Err = c.PutSecret(ctx, projectId, change.Key, desired[change.Key])
+		case SecretDelete:
+			change.Err = c.DeleteSecret(ctx, projectId, change.Key)
+		}
+		if change.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return plan, fmt.Errorf("%d of %d secret changes failed", failed, len(plan.Changes))
+	}
+	return plan, nil
+}
@@ -0,0 +1,109 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSecretsFromDotenv(t *testing.T) {
+	env := `
+# production secrets
+td.apikey=1/abcdef
+export db.password="p@ss \"word\"\n2"
+aws.key='literal \n' 
+plain=value # comment
+empty=
+`
+	secrets, err := SecretsFromDotenv(strings.NewReader(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"td.apikey":   "1/abcdef",
+		"db.password": "p@ss \"word\"\n2",
+		"aws.key":     `literal \n`,
+		"plain":       "value",
+		"empty":       "",
+	}
+	if len(secrets) != len(want) {
+		t.Fatalf("secrets wrong. got=%q", secrets)
+	}
+	for k, v := range want {
+		if secrets[k] != v {
+			t.Fatalf("secret %s wrong. want=%q, got=%q", k, v, secrets[k])
+		}
+	}
+
+	_, err = SecretsFromDotenv(strings.NewReader(`key="sup3rsecret`))
+	if err == nil || strings.Contains(err.Error(), "sup3rsecret") {
+		t.Fatalf("expected an error without the value, got %v", err)
+	}
+}
+
+func TestSecretsFromEnv(t *testing.T) {
+	t.Setenv("SYNC_TEST_TD__APIKEY", "1/abc")
+	t.Setenv("SYNC_TEST_DB", "x")
+	secrets := SecretsFromEnv("SYNC_TEST_")
+	if len(secrets) != 2 || secrets["td.apikey"] != "1/abc" || secrets["db"] != "x" {
+		t.Fatalf("secrets wrong. got=%v", secrets)
+	}
+}
+
+func TestClient_SyncSecrets(t *testing.T) {
+	tt := []struct {
+		name     string
+		opts     SecretSyncOptions
+		expected string
+		requests []string
+	}{
+		{
+			name:     "dry run",
+			opts:     SecretSyncOptions{DryRun: true},
+			expected: "create db.password\ndelete old.key\nupdate td.apikey\n",
+		},
+		{
+			name:     "apply",
+			expected: "create db.password\ndelete old.key\nupdate td.apikey\n",
+			requests: []string{"PUT db.password", "DELETE old.key", "PUT td.apikey"},
+		},
+		{
+			name:     "never delete",
+			opts:     SecretSyncOptions{NoDelete: true},
+			expected: "create db.password\nkeep   old.key\nupdate td.apikey\n",
+			requests: []string{"PUT db.password", "PUT td.apikey"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []string
+			record := func(w http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req.Method+" "+req.URL.Path[len("/projects/1/secrets/"):])
+			}
+			client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+				"GET /projects/1/secrets": func(w http.ResponseWriter, req *http.Request) {
+					w.Write([]byte(`{"secrets": [{"key": "td.apikey"}, {"key": "old.key"}]}`))
+				},
+				"PUT /projects/1/secrets/db.password": record,
+				"PUT /projects/1/secrets/td.apikey":   record,
+				"DELETE /projects/1/secrets/old.key":  record,
+			})
+			defer teardown()
+
+			plan, err := client.SyncSecrets(context.Background(), "1", map[string]string{"td.apikey": "new", "db.password": "hunter2"}, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.String() != tc.expected {
+				t.Fatalf("plan wrong. want=%q, got=%q", tc.expected, plan.String())
+			}
+			if strings.Join(requests, ",") != strings.Join(tc.requests, ",") {
+				t.Fatalf("requests wrong. want=%v, got=%v", tc.requests, requests)
+			}
+			if strings.Contains(plan.String(), "hunter2") {
+				t.Fatal("plan must not contain values")
+			}
+		})
+	}
+}
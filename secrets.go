@@ -0,0 +1,177 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxSecretKeyLength   = 255
+	maxSecretValueLength = 16 * 1024
+)
+
+// secretKeySegment is a dot-separated segment of a secret key, as validated by Digdag.
+var secretKeySegment = regexp.MustCompile(`^[a-zA-Z]([a-zA-Z0-9_-]*[a-zA-Z0-9])?$`)
+
+// ValidateSecretKey checks a key against Digdag's rules: at most 255 characters of dot-separated
+// segments which start with a letter, end with a letter or digit, and contain letters, digits, '_' and '-'.
+func ValidateSecretKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key is empty", ErrInvalidSecret)
+	}
+	if len(key) > maxSecretKeyLength {
+		return fmt.Errorf("%w: key %q is longer than %d characters", ErrInvalidSecret, key, maxSecretKeyLength)
+	}
+	for _, segment := range strings.Split(key, ".") {
+		if !secretKeySegment.MatchString(segment) {
+			return fmt.Errorf("%w: key %q has an invalid segment %q", ErrInvalidSecret, key, segment)
+		}
+	}
+	return nil
+}
+
+// ValidateSecretValue checks the length limit of a secret value. The value is never part of the error.
+func ValidateSecretValue(value string) error {
+	if utf8.RuneCountInString(value) > maxSecretValueLength {
+		return fmt.Errorf("%w: value is longer than %d characters", ErrInvalidSecret, maxSecretValueLength)
+	}
+	return nil
+}
+
+type SecretKey struct {
+	Key string `json:"key"`
+}
+
+// Secrets lists the keys of the secrets of a project. Values can't be read back.
+type Secrets struct {
+	Secrets []SecretKey `json:"secrets"`
+}
+
+// Keys returns the secret keys in order.
+func (s *Secrets) Keys() []string {
+	keys := make([]string, 0, len(s.Secrets))
+	for _, secret := range s.Secrets {
+		keys = append(keys, secret.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Client) GetSecrets(ctx context.Context, projectId string) (*Secrets, error) {
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("projects/%s/secrets", projectId), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+	var secrets Secrets
+	err = c.decodeBody(resp, &secrets)
+	if err != nil {
+		return nil, err
+	}
+	return &secrets, nil
+
+}
+
+// PutSecret sets a secret of a project, creating or replacing it.
+func (c *Client) PutSecret(ctx context.Context, projectId, key, value string) error {
+	if err := ValidateSecretKey(key); err != nil {
+		return err
+	}
+	if err := ValidateSecretValue(value); err != nil {
+		return err
+	}
+	jsn, err := json.Marshal(struct {
+		Value string `json:"value"`
+	}{Value: value})
+	if err != nil {
+		return err
+	}
+	header := map[string]string{"content-type": "application/json"}
+	req, err := c.newRequest(ctx, "PUT", fmt.Sprintf("projects/%s/secrets/%s", projectId, key), nil, bytes.NewReader(jsn), header)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return checkStatus
+	}
+	return nil
+}
+
+// PutSecrets sets several secrets of a project, in key order. Every key and value is validated before
+// the first secret is set.
+func (c *Client) PutSecrets(ctx context.Context, projectId string, secrets map[string]string) error {
+	keys := make([]string, 0, len(secrets))
+	for key, value := range secrets {
+		if err := ValidateSecretKey(key); err != nil {
+			return err
+		}
+		if err := ValidateSecretValue(value); err != nil {
+			return fmt.Errorf("secret %s: %w", key, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := c.PutSecret(ctx, projectId, key, secrets[key]); err != nil {
+			return fmt.Errorf("secret %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) DeleteSecret(ctx context.Context, projectId, key string) error {
+	if err := ValidateSecretKey(key); err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, "DELETE", fmt.Sprintf("projects/%s/secrets/%s", projectId, key), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return checkStatus
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateSecretKey(t *testing.T) {
+	tt := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "td.apikey", valid: true},
+		{key: "aws.s3.secret_access_key", valid: true},
+		{key: "a", valid: true},
+		{key: "my-key2", valid: true},
+		{key: "", valid: false},
+		{key: "_private", valid: false},
+		{key: "trailing_", valid: false},
+		{key: "td..apikey", valid: false},
+		{key: "1password", valid: false},
+		{key: "has space", valid: false},
+		{key: strings.Repeat("a", 256), valid: false},
+	}
+	for _, tc := range tt {
+		err := ValidateSecretKey(tc.key)
+		if tc.valid && err != nil {
+			t.Fatalf("key %q must be valid: %s", tc.key, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidSecret) {
+			t.Fatalf("key %q must be invalid, got %v", tc.key, err)
+		}
+	}
+}
+
+func TestClient_Secrets(t *testing.T) {
+	put := map[string]string{}
+	deleted := ""
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects/1/secrets": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"secrets": [{"key": "td.apikey"}, {"key": "aws.key"}]}`))
+		},
+		"PUT /projects/1/secrets/db.password": func(w http.ResponseWriter, req *http.Request) {
+			var body map[string]string
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			put["db.password"] = body["value"]
+		},
+		"DELETE /projects/1/secrets/aws.key": func(w http.ResponseWriter, req *http.Request) {
+			deleted = "aws.key"
+		},
+	})
+	defer teardown()
+	ctx := context.Background()
+
+	secrets, err := client.GetSecrets(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := secrets.Keys(); len(keys) != 2 || keys[0] != "aws.key" || keys[1] != "td.apikey" {
+		t.Fatalf("keys wrong. got=%v", keys)
+	}
+	if err := client.PutSecret(ctx, "1", "db.password", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+	if put["db.password"] != "s3cr3t" {
+		t.Fatalf("secret value wrong. got=%v", put)
+	}
+	if err := client.DeleteSecret(ctx, "1", "aws.key"); err != nil {
+		t.Fatal(err)
+	}
+	if deleted != "aws.key" {
+		t.Fatal("secret was not deleted")
+	}
+	if err := client.PutSecrets(ctx, "1", map[string]string{"db.password": "x", "bad key": "y"}); !errors.Is(err, ErrInvalidSecret) {
+		t.Fatalf("expected invalid secret error, got %v", err)
+	}
+}
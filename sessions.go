@@ -0,0 +1,132 @@
+package digdaggo
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// GetSessions lists the sessions of every project, newest first.
+// Pass the ID of the last session of the previous page as lastId to get the next page.
+func (c *Client) GetSessions(ctx context.Context, lastId, pageSize string) (*Sessions, error) {
+	parameters := map[string]string{}
+	if lastId != "" {
+		parameters["last_id"] = lastId
+	}
+	if pageSize != "" {
+		parameters["page_size"] = pageSize
+	}
+
+	req, err := c.newRequest(ctx, "GET", "sessions", parameters, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+
+	var sessions Sessions
+	err = c.decodeBody(resp, &sessions)
+	if err != nil {
+		return nil, err
+	}
+	return &sessions, nil
+}
+
+// GetSession looks up a session by its ID without knowing its project.
+func (c *Client) GetSession(ctx context.Context, sessionId string) (*Session, error) {
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("sessions/%s", sessionId), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+
+	var session Session
+	err = c.decodeBody(resp, &session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionAttempts lists one page of the attempts of a session, retried attempts included.
+func (c *Client) GetSessionAttempts(ctx context.Context, sessionId, lastId, pageSize string) (*AttemptList, error) {
+	parameters := map[string]string{}
+	if lastId != "" {
+		parameters["last_id"] = lastId
+	}
+	if pageSize != "" {
+		parameters["page_size"] = pageSize
+	}
+
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("sessions/%s/attempts", sessionId), parameters, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+
+		}
+	}(resp.Body)
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+
+	var attemptList AttemptList
+	err = c.decodeBody(resp, &attemptList)
+	if err != nil {
+		return nil, err
+	}
+	return &attemptList, nil
+}
+
+// GetAllSessionAttempts follows the pagination of GetSessionAttempts and returns every attempt of a session.
+func (c *Client) GetAllSessionAttempts(ctx context.Context, sessionId string) (*AttemptList, error) {
+	var all AttemptList
+	lastId := ""
+	for {
+		page, err := c.GetSessionAttempts(ctx, sessionId, lastId, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Attempts) == 0 {
+			return &all, nil
+		}
+		all.Attempts = append(all.Attempts, page.Attempts...)
+		next := page.Attempts[len(page.Attempts)-1].ID
+		if next == lastId {
+			return &all, nil
+		}
+		lastId = next
+	}
+}
@@ -0,0 +1,78 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient_GetSession(t *testing.T) {
+	testSessionTime, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00Z")
+	tt := []struct {
+		name                string
+		expectedMethod      string
+		expectedRequestPath string
+		expectedSession     *Session
+	}{
+		{
+			name: "success",
+
+			expectedMethod:      "GET",
+			expectedRequestPath: "/sessions/42",
+			expectedSession: &Session{
+				ID:          "42",
+				Project:     ShortProject{ID: "1", Name: "test"},
+				Workflow:    Workflow{ID: "7", Name: "daily"},
+				SessionUUID: "8b7add0f-ea24-420f-a041-135c4c8c4a32",
+				SessionTime: testSessionTime,
+				LastAttempt: LastAttempt{ID: "100", Done: true, Success: true},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client, teardown := setup(t, tc.expectedSession, tc.expectedMethod, tc.expectedRequestPath)
+			defer teardown()
+
+			session, err := client.GetSession(context.Background(), "42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.ID != tc.expectedSession.ID ||
+				session.Project != tc.expectedSession.Project ||
+				session.Workflow != tc.expectedSession.Workflow ||
+				session.SessionUUID != tc.expectedSession.SessionUUID ||
+				!session.SessionTime.Equal(tc.expectedSession.SessionTime) ||
+				session.LastAttempt.ID != tc.expectedSession.LastAttempt.ID {
+				t.Fatalf("response items wrong. want=%+v, got=%+v", tc.expectedSession, session)
+			}
+		})
+	}
+}
+
+func TestClient_GetAllSessionAttempts(t *testing.T) {
+	pages := map[string]AttemptList{
+		"":   {Attempts: []Attempt{{ID: "12"}, {ID: "11"}}},
+		"11": {Attempts: []Attempt{{ID: "10"}}},
+		"10": {Attempts: []Attempt{}},
+	}
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /sessions/42/attempts": func(w http.ResponseWriter, req *http.Request) {
+			respondJSON(t, pages[req.URL.Query().Get("last_id")])(w, req)
+		},
+	})
+	defer teardown()
+
+	attempts, err := client.GetAllSessionAttempts(context.Background(), "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range attempts.Attempts {
+		got = append(got, a.ID)
+	}
+	if len(got) != 3 || got[0] != "12" || got[1] != "11" || got[2] != "10" {
+		t.Fatalf("attempts wrong. want=[12 11 10], got=%v", got)
+	}
+}
@@ -0,0 +1,270 @@
+package digdaggo
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// StatsOptions configures WorkflowStatistics. Zero fields take their defaults.
+type StatsOptions struct {
+	// Project and Workflow restrict the workflows which are reported.
+	Project  string
+	Workflow string
+	// End is the end of the reported window. Defaults to the current time.
+	End time.Time
+	// Window is the length of the reported window. Defaults to 7 days.
+	Window time.Duration
+	// Baseline is the length of the period right before the window which durations are compared with.
+	// Defaults to Window.
+	Baseline time.Duration
+	// RegressionThreshold is the ratio of the window's p50 or p90 to the baseline's above which a
+	// regression is reported. Defaults to 1.2.
+	RegressionThreshold float64
+	// CorrelateRevisions looks up the project revision each attempt ran on.
+	CorrelateRevisions bool
+}
+
+// DurationStats summarizes the durations of successful attempts.
+type DurationStats struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+}
+
+func newDurationStats(durations []time.Duration) DurationStats {
+	return DurationStats{
+		Count: len(durations),
+		P50:   durationPercentile(durations, 50),
+		P90:   durationPercentile(durations, 90),
+		P99:   durationPercentile(durations, 99),
+	}
+}
+
+// RevisionStats summarizes the attempts of a workflow which ran on one project revision.
+type RevisionStats struct {
+	Revision  string        `json:"revision"`
+	Attempts  int           `json:"attempts"`
+	Successes int           `json:"successes"`
+	Durations DurationStats `json:"durations"`
+}
+
+// DurationRegression reports that a workflow got slower than in the baseline period.
+type DurationRegression struct {
+	// Metric is "p50" or "p90".
+	Metric   string        `json:"metric"`
+	Baseline time.Duration `json:"baseline"`
+	Current  time.Duration `json:"current"`
+	Ratio    float64       `json:"ratio"`
+	// SuspectRevisions are the revisions which ran in the window but not in the baseline period.
+	SuspectRevisions []string `json:"suspectRevisions,omitempty"`
+}
+
+// WorkflowStats are the statistics of one workflow over the window.
+type WorkflowStats struct {
+	Project   string `json:"project"`
+	Workflow  string `json:"workflow"`
+	Attempts  int    `json:"attempts"`
+	Successes int    `json:"successes"`
+	Failures  int    `json:"failures"`
+	// Killed attempts count neither as successes nor as failures.
+	Killed      int           `json:"killed"`
+	SuccessRate float64       `json:"successRate"`
+	Durations   DurationStats `json:"durations"`
+	// LongestFailureStreak is the longest run of consecutive failed attempts in the window.
+	LongestFailureStreak int `json:"longestFailureStreak"`
+	// CurrentFailureStreak is the number of consecutive failed attempts at the end of the window.
+	CurrentFailureStreak int                 `json:"currentFailureStreak"`
+	BaselineDurations    DurationStats       `json:"baselineDurations"`
+	Regression           *DurationRegression `json:"regression,omitempty"`
+	// Revisions are set with CorrelateRevisions, oldest first.
+	Revisions []RevisionStats `json:"revisions,omitempty"`
+}
+
+// WorkflowStatistics computes per-workflow duration statistics, success rates and failure streaks of the
+// finished attempts created in the window, and compares durations with the baseline period.
+func (c *Client) WorkflowStatistics(ctx context.Context, opts StatsOptions) ([]WorkflowStats, error) {
+	if opts.End.IsZero() {
+		opts.End = time.Now()
+	}
+	if opts.Window <= 0 {
+		opts.Window = 7 * 24 * time.Hour
+	}
+	if opts.Baseline <= 0 {
+		opts.Baseline = opts.Window
+	}
+	if opts.RegressionThreshold <= 0 {
+		opts.RegressionThreshold = 1.2
+	}
+	windowStart := opts.End.Add(-opts.Window)
+	baselineStart := windowStart.Add(-opts.Baseline)
+
+	type history struct {
+		window   []Attempt
+		baseline []Attempt
+	}
+	histories := map[[2]string]*history{}
+	var keys [][2]string
+	err := c.forEachAttempt(ctx, opts.Project, opts.Workflow, true, func(a *Attempt) (bool, error) {
+		if a.CreatedAt.Before(baselineStart) {
+			return false, nil
+		}
+		if !a.Done || !a.CreatedAt.Before(opts.End) {
+			return true, nil
+		}
+		key := [2]string{a.Project.Name, a.Workflow.Name}
+		h, ok := histories[key]
+		if !ok {
+			h = &history{}
+			histories[key] = h
+			keys = append(keys, key)
+		}
+		if a.CreatedAt.Before(windowStart) {
+			h.baseline = append(h.baseline, *a)
+		} else {
+			h.window = append(h.window, *a)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := map[string]string{}
+	revisionOf := func(a *Attempt) (string, error) {
+		if rev, ok := revisions[a.Workflow.ID]; ok {
+			return rev, nil
+		}
+		wf, err := c.GetWorkflowWithID(ctx, a.Workflow.ID)
+		if err != nil {
+			return "", err
+		}
+		revisions[a.Workflow.ID] = wf.Revision
+		return wf.Revision, nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	var stats []WorkflowStats
+	for _, key := range keys {
+		h := histories[key]
+		if len(h.window) == 0 {
+			continue
+		}
+		sortAttemptsByCreation(h.window)
+		s := WorkflowStats{Project: key[0], Workflow: key[1]}
+		var durations []time.Duration
+		streak := 0
+		for i := range h.window {
+			a := &h.window[i]
+			s.Attempts++
+			switch {
+			case a.Success:
+				s.Successes++
+				durations = append(durations, a.FinishedAt.Sub(a.CreatedAt))
+				streak = 0
+			case a.CancelRequested:
+				s.Killed++
+			default:
+				s.Failures++
+				streak++
+				if streak > s.LongestFailureStreak {
+					s.LongestFailureStreak = streak
+				}
+			}
+		}
+		s.CurrentFailureStreak = streak
+		if s.Successes+s.Failures > 0 {
+			s.SuccessRate = float64(s.Successes) / float64(s.Successes+s.Failures)
+		}
+		s.Durations = newDurationStats(durations)
+		s.BaselineDurations = newDurationStats(successfulDurations(h.baseline))
+		s.Regression = detectRegression(s.BaselineDurations, s.Durations, opts.RegressionThreshold)
+
+		if opts.CorrelateRevisions {
+			byRevision := map[string]*RevisionStats{}
+			revisionDurations := map[string][]time.Duration{}
+			for i := range h.window {
+				a := &h.window[i]
+				rev, err := revisionOf(a)
+				if err != nil {
+					return nil, err
+				}
+				rs, ok := byRevision[rev]
+				if !ok {
+					rs = &RevisionStats{Revision: rev}
+					byRevision[rev] = rs
+					s.Revisions = append(s.Revisions, RevisionStats{Revision: rev})
+				}
+				rs.Attempts++
+				if a.Success {
+					rs.Successes++
+					revisionDurations[rev] = append(revisionDurations[rev], a.FinishedAt.Sub(a.CreatedAt))
+				}
+			}
+			for i := range s.Revisions {
+				rev := s.Revisions[i].Revision
+				s.Revisions[i] = *byRevision[rev]
+				s.Revisions[i].Durations = newDurationStats(revisionDurations[rev])
+			}
+			if s.Regression != nil {
+				baselineRevisions := map[string]bool{}
+				for i := range h.baseline {
+					rev, err := revisionOf(&h.baseline[i])
+					if err != nil {
+						return nil, err
+					}
+					baselineRevisions[rev] = true
+				}
+				for _, rs := range s.Revisions {
+					if !baselineRevisions[rs.Revision] {
+						s.Regression.SuspectRevisions = append(s.Regression.SuspectRevisions, rs.Revision)
+					}
+				}
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// detectRegression compares p90 first, then p50. Nothing is reported without successful attempts in both periods.
+func detectRegression(baseline, current DurationStats, threshold float64) *DurationRegression {
+	if baseline.Count == 0 || current.Count == 0 {
+		return nil
+	}
+	for _, m := range []struct {
+		name              string
+		baseline, current time.Duration
+	}{
+		{"p90", baseline.P90, current.P90},
+		{"p50", baseline.P50, current.P50},
+	} {
+		if m.baseline <= 0 {
+			continue
+		}
+		ratio := float64(m.current) / float64(m.baseline)
+		if ratio > threshold {
+			return &DurationRegression{Metric: m.name, Baseline: m.baseline, Current: m.current, Ratio: ratio}
+		}
+	}
+	return nil
+}
+
+func successfulDurations(attempts []Attempt) []time.Duration {
+	var durations []time.Duration
+	for i := range attempts {
+		if attempts[i].Success {
+			durations = append(durations, attempts[i].FinishedAt.Sub(attempts[i].CreatedAt))
+		}
+	}
+	return durations
+}
+
+func sortAttemptsByCreation(attempts []Attempt) {
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return attempts[i].CreatedAt.Before(attempts[j].CreatedAt)
+	})
+}
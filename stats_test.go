@@ -0,0 +1,83 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClient_WorkflowStatistics(t *testing.T) {
+	end, _ := time.Parse(time.RFC3339, "2022-04-08T00:00:00Z")
+	var attempts []Attempt
+	add := func(daysAgo int, workflowID string, success bool, duration time.Duration) {
+		created := end.Add(-time.Duration(daysAgo) * 24 * time.Hour)
+		attempts = append(attempts, Attempt{
+			ID:         strconv.Itoa(1000 - len(attempts)),
+			Project:    ProjectInAttempt{Name: "test"},
+			Workflow:   WorkflowInAttempt{ID: workflowID, Name: "daily"},
+			Done:       true,
+			Success:    success,
+			CreatedAt:  created,
+			FinishedAt: created.Add(duration),
+		})
+	}
+	// window: newest first, as the server lists them
+	add(1, "200", false, time.Minute)
+	add(2, "200", false, time.Minute)
+	add(3, "200", true, 2*time.Hour)
+	add(4, "200", false, time.Minute)
+	add(5, "100", true, time.Hour)
+	add(6, "100", true, time.Hour)
+	// baseline
+	add(8, "100", true, time.Hour)
+	add(9, "100", true, time.Hour)
+	// outside of both periods
+	add(20, "100", true, time.Hour)
+
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("include_retried") != "true" {
+				t.Error("retried attempts must be included")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: attempts})(w, req)
+		},
+		"GET /workflows/100": respondJSON(t, DetailedWorkflow{ID: "100", Revision: "r1"}),
+		"GET /workflows/200": respondJSON(t, DetailedWorkflow{ID: "200", Revision: "r2"}),
+	})
+	defer teardown()
+
+	stats, err := client.WorkflowStatistics(context.Background(), StatsOptions{Project: "test", End: end, CorrelateRevisions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("stats wrong. got=%+v", stats)
+	}
+	s := stats[0]
+	if s.Attempts != 6 || s.Successes != 3 || s.Failures != 3 || s.SuccessRate != 0.5 {
+		t.Fatalf("counts wrong. got=%+v", s)
+	}
+	if s.LongestFailureStreak != 2 || s.CurrentFailureStreak != 2 {
+		t.Fatalf("failure streaks wrong. got longest=%d current=%d", s.LongestFailureStreak, s.CurrentFailureStreak)
+	}
+	if s.Durations.P50 != time.Hour || s.Durations.P90 != 2*time.Hour || s.BaselineDurations.Count != 2 {
+		t.Fatalf("durations wrong. got=%+v baseline=%+v", s.Durations, s.BaselineDurations)
+	}
+	if s.Regression == nil || s.Regression.Metric != "p90" || s.Regression.Ratio != 2 {
+		t.Fatalf("regression wrong. got=%+v", s.Regression)
+	}
+	if len(s.Regression.SuspectRevisions) != 1 || s.Regression.SuspectRevisions[0] != "r2" {
+		t.Fatalf("suspect revisions wrong. got=%v", s.Regression.SuspectRevisions)
+	}
+	if len(s.Revisions) != 2 || s.Revisions[0].Revision != "r1" || s.Revisions[1].Attempts != 4 {
+		t.Fatalf("revisions wrong. got=%+v", s.Revisions)
+	}
+}
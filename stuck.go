@@ -0,0 +1,210 @@
+package digdaggo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// StuckOptions configures DetectStuckAttempts. Zero fields take their defaults.
+type StuckOptions struct {
+	// Project and Workflow restrict the attempts which are scanned.
+	Project  string
+	Workflow string
+	// ExpectedDurations maps "project/workflow" to the expected duration of an attempt.
+	// Workflows without an entry learn it from their history.
+	ExpectedDurations map[string]time.Duration
+	// HistorySize is the number of recent successful attempts to learn an expected duration from. Defaults to 20.
+	HistorySize int
+	// HistoryWindow only learns from attempts created this recently. Defaults to 30 days.
+	HistoryWindow time.Duration
+	// Tolerance multiplies the 90th percentile of the learned durations. Defaults to 1.5.
+	Tolerance float64
+	// StaleAfter flags running tasks which haven't been updated for this long. Defaults to one hour.
+	StaleAfter time.Duration
+	// RetryThreshold flags tasks waiting for their retry after this many retries. Defaults to 3.
+	RetryThreshold int
+	// MaxAge ignores attempts created longer ago. Defaults to 7 days.
+	MaxAge time.Duration
+	// Now defaults to the current time.
+	Now time.Time
+}
+
+// StuckKind is the reason an attempt was flagged.
+type StuckKind string
+
+const (
+	StuckLongRunning StuckKind = "long_running"
+	StuckStaleTask   StuckKind = "stale_task"
+	StuckRetrying    StuckKind = "retrying_task"
+)
+
+// StuckFinding is a running attempt or task which looks stuck.
+type StuckFinding struct {
+	Kind    StuckKind `json:"kind"`
+	Attempt Attempt   `json:"attempt"`
+	// Task is the full name of the task, empty for attempt findings.
+	Task string `json:"task,omitempty"`
+	// Elapsed is how long the attempt has been running, or how long the task hasn't been updated.
+	Elapsed time.Duration `json:"elapsed"`
+	// Threshold is the duration or retry count which was exceeded.
+	Threshold time.Duration `json:"threshold,omitempty"`
+	Retries   int           `json:"retries,omitempty"`
+	Message   string        `json:"message"`
+}
+
+// StuckReport is the result of DetectStuckAttempts.
+type StuckReport struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Findings    []StuckFinding `json:"findings"`
+	// ExpectedDurations are the expected durations which were configured or learned, by "project/workflow".
+	ExpectedDurations map[string]time.Duration `json:"expectedDurations"`
+}
+
+// DetectStuckAttempts scans the running attempts for attempts exceeding the expected duration of their
+// workflow, tasks which stopped making progress, and tasks which keep retrying.
+func (c *Client) DetectStuckAttempts(ctx context.Context, opts StuckOptions) (*StuckReport, error) {
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = 20
+	}
+	if opts.HistoryWindow <= 0 {
+		opts.HistoryWindow = 30 * 24 * time.Hour
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 1.5
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = time.Hour
+	}
+	if opts.RetryThreshold <= 0 {
+		opts.RetryThreshold = 3
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 7 * 24 * time.Hour
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	cutoff := opts.Now.Add(-opts.MaxAge)
+	var running []Attempt
+	err := c.forEachAttempt(ctx, opts.Project, opts.Workflow, false, func(a *Attempt) (bool, error) {
+		if a.CreatedAt.Before(cutoff) {
+			return false, nil
+		}
+		if !a.Done {
+			running = append(running, *a)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StuckReport{GeneratedAt: opts.Now, ExpectedDurations: map[string]time.Duration{}}
+	for i := range running {
+		a := &running[i]
+		key := a.Project.Name + "/" + a.Workflow.Name
+		expected, ok := report.ExpectedDurations[key]
+		if !ok {
+			expected, ok = opts.ExpectedDurations[key]
+			if !ok {
+				expected, err = c.learnExpectedDuration(ctx, a.Project.Name, a.Workflow.Name, opts)
+				if err != nil {
+					return nil, err
+				}
+			}
+			report.ExpectedDurations[key] = expected
+		}
+		elapsed := opts.Now.Sub(a.CreatedAt)
+		if expected > 0 && elapsed > expected {
+			report.Findings = append(report.Findings, StuckFinding{
+				Kind:      StuckLongRunning,
+				Attempt:   *a,
+				Elapsed:   elapsed,
+				Threshold: expected,
+				Message:   fmt.Sprintf("attempt has been running for %s, expected at most %s", elapsed.Round(time.Second), expected.Round(time.Second)),
+			})
+		}
+
+		tasks, err := c.ListTasks(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks.Tasks {
+			if task.IsGroup {
+				continue
+			}
+			if task.State == "running" && !task.UpdatedAt.IsZero() {
+				if idle := opts.Now.Sub(task.UpdatedAt); idle > opts.StaleAfter {
+					report.Findings = append(report.Findings, StuckFinding{
+						Kind:      StuckStaleTask,
+						Attempt:   *a,
+						Task:      task.FullName,
+						Elapsed:   idle,
+						Threshold: opts.StaleAfter,
+						Message:   fmt.Sprintf("task has not been updated for %s", idle.Round(time.Second)),
+					})
+				}
+			}
+			if retries := taskRetryCount(&task); !task.RetryAt.IsZero() && retries >= opts.RetryThreshold {
+				report.Findings = append(report.Findings, StuckFinding{
+					Kind:    StuckRetrying,
+					Attempt: *a,
+					Task:    task.FullName,
+					Retries: retries,
+					Message: fmt.Sprintf("task was retried %d times and retries again at %s", retries, task.RetryAt.Format(time.RFC3339)),
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+// learnExpectedDuration returns the 90th percentile of the durations of the last HistorySize successful
+// attempts within the HistoryWindow, multiplied by the tolerance. It returns zero when the workflow hasn't
+// succeeded within the window.
+func (c *Client) learnExpectedDuration(ctx context.Context, projectName, workflowName string, opts StuckOptions) (time.Duration, error) {
+	since := opts.Now.Add(-opts.HistoryWindow)
+	var durations []time.Duration
+	err := c.forEachAttempt(ctx, projectName, workflowName, false, func(a *Attempt) (bool, error) {
+		if a.CreatedAt.Before(since) {
+			return false, nil
+		}
+		if a.Done && a.Success && !a.FinishedAt.IsZero() {
+			durations = append(durations, a.FinishedAt.Sub(a.CreatedAt))
+		}
+		return len(durations) < opts.HistorySize, nil
+	})
+	if err != nil || len(durations) == 0 {
+		return 0, err
+	}
+	return time.Duration(float64(durationPercentile(durations, 90)) * opts.Tolerance), nil
+}
+
+// taskRetryCount returns the number of retries the task has made, as kept in its state params.
+func taskRetryCount(task *Task) int {
+	switch n := task.StateParams["retry_count"].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// durationPercentile returns the p-th percentile of the durations using the nearest-rank method.
+func durationPercentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,133 @@
+package digdaggo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClient_DetectStuckAttempts(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2022-04-01T12:00:00Z")
+	running := Attempt{ID: "50", Project: ProjectInAttempt{Name: "test"}, Workflow: WorkflowInAttempt{Name: "daily"}, CreatedAt: now.Add(-3 * time.Hour)}
+	configured := Attempt{ID: "60", Project: ProjectInAttempt{Name: "test"}, Workflow: WorkflowInAttempt{Name: "hourly"}, CreatedAt: now.Add(-10 * time.Minute)}
+	history := []Attempt{running, configured}
+	for i := 0; i < 10; i++ {
+		created := now.Add(-time.Duration(24*(i+1)) * time.Hour)
+		history = append(history, Attempt{
+			ID:         strconv.Itoa(40 + i),
+			Project:    ProjectInAttempt{Name: "test"},
+			Workflow:   WorkflowInAttempt{Name: "daily"},
+			Done:       true,
+			Success:    true,
+			CreatedAt:  created,
+			FinishedAt: created.Add(time.Hour),
+		})
+	}
+
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				respondJSON(t, AttemptList{})(w, req)
+				return
+			}
+			var attempts []Attempt
+			for _, a := range history {
+				if wf := req.URL.Query().Get("workflow"); wf == "" || wf == a.Workflow.Name {
+					attempts = append(attempts, a)
+				}
+			}
+			respondJSON(t, AttemptList{Attempts: attempts})(w, req)
+		},
+		"GET /attempts/50/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": [
+				{"fullName": "+daily", "state": "planned", "isGroup": true, "updatedAt": "2022-04-01T09:00:00Z"},
+				{"fullName": "+daily+load", "state": "running", "updatedAt": "2022-04-01T09:30:00Z"},
+				{"fullName": "+daily+export", "state": "retry_waiting", "updatedAt": "2022-04-01T11:50:00Z",
+				 "retryAt": "2022-04-01T12:10:00Z", "stateParams": {"retry_count": 4}}
+			]}`))
+		},
+		"GET /attempts/60/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": [{"fullName": "+hourly+load", "state": "running", "updatedAt": "2022-04-01T11:55:00Z"}]}`))
+		},
+	})
+	defer teardown()
+
+	report, err := client.DetectStuckAttempts(context.Background(), StuckOptions{
+		Project:           "test",
+		ExpectedDurations: map[string]time.Duration{"test/hourly": 5 * time.Minute},
+		Now:               now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if learned := report.ExpectedDurations["test/daily"]; learned != 90*time.Minute {
+		t.Fatalf("learned duration wrong. want=1h30m, got=%s", learned)
+	}
+
+	found := map[StuckKind][]StuckFinding{}
+	for _, f := range report.Findings {
+		found[f.Kind] = append(found[f.Kind], f)
+	}
+	if len(found[StuckLongRunning]) != 2 {
+		t.Fatalf("long running attempts wrong. got=%+v", found[StuckLongRunning])
+	}
+	if stale := found[StuckStaleTask]; len(stale) != 1 || stale[0].Task != "+daily+load" || stale[0].Elapsed != 150*time.Minute {
+		t.Fatalf("stale tasks wrong. got=%+v", stale)
+	}
+	if retrying := found[StuckRetrying]; len(retrying) != 1 || retrying[0].Task != "+daily+export" || retrying[0].Retries != 4 {
+		t.Fatalf("retrying tasks wrong. got=%+v", retrying)
+	}
+}
+
+func TestClient_DetectStuckAttempts_historyWindow(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2022-04-01T12:00:00Z")
+	running := Attempt{ID: "9", Project: ProjectInAttempt{Name: "test"}, Workflow: WorkflowInAttempt{Name: "daily"}, CreatedAt: now.Add(-time.Hour)}
+	succeeded := func(id string, age, duration time.Duration) Attempt {
+		created := now.Add(-age)
+		return Attempt{ID: id, Project: ProjectInAttempt{Name: "test"}, Workflow: WorkflowInAttempt{Name: "daily"},
+			Done: true, Success: true, CreatedAt: created, FinishedAt: created.Add(duration)}
+	}
+	history := []Attempt{
+		running,
+		succeeded("8", 24*time.Hour, 20*time.Minute),
+		succeeded("7", 48*time.Hour, 20*time.Minute),
+		// older than the window, from before the workflow got faster
+		succeeded("6", 10*24*time.Hour, 5*time.Hour),
+	}
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /attempts": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("last_id") != "" {
+				t.Errorf("history was paged past the window: %s", req.URL.RawQuery)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, AttemptList{Attempts: history})(w, req)
+		},
+		"GET /attempts/9/tasks": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"tasks": []}`))
+		},
+	})
+	defer teardown()
+
+	report, err := client.DetectStuckAttempts(context.Background(), StuckOptions{Project: "test", HistoryWindow: 7 * 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if learned := report.ExpectedDurations["test/daily"]; learned != 30*time.Minute {
+		t.Fatalf("learned duration wrong. want=30m, got=%s", learned)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Kind != StuckLongRunning {
+		t.Fatalf("findings wrong. got=%+v", report.Findings)
+	}
+}
+
+func TestDurationPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	for p, want := range map[float64]time.Duration{50: 5, 90: 9, 99: 10, 0: 1} {
+		if got := durationPercentile(durations, p); got != want {
+			t.Fatalf("p%.0f wrong. want=%d, got=%d", p, want, got)
+		}
+	}
+}
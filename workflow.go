@@ -2,7 +2,15 @@ package digdaggo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"digdagGo/dig"
 )
 
 type WorkflowsList struct {
@@ -21,6 +29,46 @@ type DetailedWorkflow struct {
 	Revision string            `json:"revision"`
 	Timezone string            `json:"timezone"`
 	Config   interface{}       `json:"config"`
+
+	// rawConfig keeps the config as received, with the order of its tasks.
+	rawConfig json.RawMessage
+}
+
+func (w *DetailedWorkflow) UnmarshalJSON(data []byte) error {
+	type detailedWorkflow DetailedWorkflow
+	var raw struct {
+		detailedWorkflow
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*w = DetailedWorkflow(raw.detailedWorkflow)
+	w.rawConfig = raw.Config
+	if len(raw.Config) > 0 {
+		return json.Unmarshal(raw.Config, &w.Config)
+	}
+	return nil
+}
+
+// Definition decodes Config into the typed workflow model. The task order is only known for
+// workflows received from the server; it's undefined when Config was set by hand.
+func (w *DetailedWorkflow) Definition() (*dig.Workflow, error) {
+	config := []byte(w.rawConfig)
+	if len(config) == 0 {
+		var err error
+		if config, err = json.Marshal(w.Config); err != nil {
+			return nil, err
+		}
+	}
+	wf, err := dig.ParseConfig(w.Name, config)
+	if err != nil {
+		return nil, err
+	}
+	if wf.Timezone == "" {
+		wf.Timezone = w.Timezone
+	}
+	return wf, nil
 }
 
 func (c *Client) GetWorkflowList(ctx context.Context, lastId, count string) (*WorkflowsList, error) {
@@ -72,3 +120,198 @@ func (c *Client) GetWorkflowWithID(ctx context.Context, workflowId string) (*Det
 	}
 	return &detailedWorkflow, nil
 }
+
+// SessionTimeMode selects how the truncated_session_time endpoint truncates a session time.
+type SessionTimeMode string
+
+const (
+	// SessionTimeHour truncates to the beginning of the hour in the workflow's time zone.
+	SessionTimeHour SessionTimeMode = "hour"
+	// SessionTimeDay truncates to the beginning of the day in the workflow's time zone.
+	SessionTimeDay SessionTimeMode = "day"
+	// SessionTimeSchedule truncates to the last schedule time of the workflow.
+	// The workflow must have a schedule.
+	SessionTimeSchedule SessionTimeMode = "schedule"
+)
+
+func (m SessionTimeMode) validate() error {
+	switch m {
+	case "", SessionTimeHour, SessionTimeDay, SessionTimeSchedule:
+		return nil
+	}
+	return fmt.Errorf("unknown session time mode %q", string(m))
+}
+
+// WorkflowSessionTime is a session time computed by the server for a workflow.
+type WorkflowSessionTime struct {
+	Project     ProjectInWorkflow `json:"project"`
+	Revision    string            `json:"revision"`
+	Workflow    Workflow          `json:"workflow"`
+	SessionTime time.Time         `json:"sessionTime"`
+	TimeZone    string            `json:"timeZone"`
+}
+
+// GetTruncatedSessionTime computes the session time of the workflow for the given instant.
+// An empty mode only converts the instant to the workflow's time zone.
+func (c *Client) GetTruncatedSessionTime(ctx context.Context, workflowId string, instant time.Time, mode SessionTimeMode) (*WorkflowSessionTime, error) {
+	if err := mode.validate(); err != nil {
+		return nil, err
+	}
+	param := map[string]string{"session_time": instant.UTC().Format(time.RFC3339)}
+	if mode != "" {
+		param["mode"] = string(mode)
+	}
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("workflows/%s/truncated_session_time", workflowId), param, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+	var sessionTime WorkflowSessionTime
+	err = c.decodeBody(resp, &sessionTime)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionTime, nil
+}
+
+// NumericID returns the workflow ID as the int64 which StartAttempt expects.
+func (w *DetailedWorkflow) NumericID() (int64, error) {
+	return strconv.ParseInt(w.ID, 10, 64)
+}
+
+// GetProjectWorkflow looks up a workflow of a project by its name.
+// When revision is empty, the workflow of the latest revision is returned.
+func (c *Client) GetProjectWorkflow(ctx context.Context, projectId, workflowName, revision string) (*DetailedWorkflow, error) {
+	if workflowName == "" {
+		return nil, errors.New("workflow name must be specified")
+	}
+	parameters := map[string]string{}
+	if revision != "" {
+		parameters["revision"] = revision
+	}
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf("projects/%s/workflows/%s", projectId, workflowName), parameters, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	checkStatus := c.checkHttpResponseCode(resp)
+	if checkStatus != nil {
+		return nil, checkStatus
+	}
+	var detailedWorkflow DetailedWorkflow
+	err = c.decodeBody(resp, &detailedWorkflow)
+	if err != nil {
+		return nil, err
+	}
+	return &detailedWorkflow, nil
+}
+
+// ResolveWorkflow resolves a "project/workflow" reference to the workflow of the latest revision.
+func (c *Client) ResolveWorkflow(ctx context.Context, ref string) (*DetailedWorkflow, error) {
+	return c.ResolveWorkflowAtRevision(ctx, ref, "")
+}
+
+// ResolveWorkflowAtRevision resolves a "project/workflow" reference to the workflow of the given revision.
+// Results are cached per client. Entries of the latest revision are dropped when the project is pushed
+// with PutProject, or by ClearWorkflowCache.
+func (c *Client) ResolveWorkflowAtRevision(ctx context.Context, ref, revision string) (*DetailedWorkflow, error) {
+	projectName, workflowName, err := parseWorkflowRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	key := workflowCacheKey{project: projectName, workflow: workflowName, revision: revision}
+	if wf, ok := c.workflows.get(key); ok {
+		return wf, nil
+	}
+
+	project, err := c.findProject(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := c.GetProjectWorkflow(ctx, project.ID, workflowName, revision)
+	if err != nil {
+		return nil, err
+	}
+	c.workflows.put(key, wf)
+	return wf, nil
+}
+
+// ClearWorkflowCache drops every workflow cached by ResolveWorkflow.
+func (c *Client) ClearWorkflowCache() {
+	c.workflows.clear()
+}
+
+func (c *Client) findProject(ctx context.Context, projectName string) (*Project, error) {
+	projects, err := c.GetProjects(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range projects.Projects {
+		if projects.Projects[i].Name == projectName {
+			return &projects.Projects[i], nil
+		}
+	}
+	return nil, fmt.Errorf("project %q: %w", projectName, ErrNotFound)
+}
+
+func parseWorkflowRef(ref string) (string, string, error) {
+	projectName, workflowName, ok := strings.Cut(ref, "/")
+	if !ok || projectName == "" || workflowName == "" {
+		return "", "", fmt.Errorf("invalid workflow reference %q: want \"project/workflow\"", ref)
+	}
+	return projectName, strings.TrimSuffix(workflowName, ".dig"), nil
+}
+
+type workflowCacheKey struct {
+	project  string
+	workflow string
+	revision string
+}
+
+type workflowCache struct {
+	mu      sync.Mutex
+	entries map[workflowCacheKey]*DetailedWorkflow
+}
+
+func (wc *workflowCache) get(key workflowCacheKey) (*DetailedWorkflow, bool) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wf, ok := wc.entries[key]
+	return wf, ok
+}
+
+func (wc *workflowCache) put(key workflowCacheKey, wf *DetailedWorkflow) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.entries == nil {
+		wc.entries = map[workflowCacheKey]*DetailedWorkflow{}
+	}
+	wc.entries[key] = wf
+}
+
+// forgetProject drops the latest-revision entries of a project. Entries pinned to a revision stay valid.
+func (wc *workflowCache) forgetProject(projectName string) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	for key := range wc.entries {
+		if key.project == projectName && key.revision == "" {
+			delete(wc.entries, key)
+		}
+	}
+}
+
+func (wc *workflowCache) clear() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.entries = nil
+}
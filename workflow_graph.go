@@ -0,0 +1,401 @@
+package digdaggo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"digdagGo/dig"
+)
+
+// ProjectFiles are the files of a project revision, such as ProjectArchive.FS.
+type ProjectFiles struct {
+	Project Project
+	FS      fs.FS
+}
+
+// WorkflowNode is a workflow of a project. Its ID is "project/workflow".
+type WorkflowNode struct {
+	ID        string `json:"id"`
+	Project   string `json:"project"`
+	Workflow  string `json:"workflow"`
+	Scheduled bool   `json:"scheduled"`
+}
+
+// WorkflowDependency is a require> or call> task of one workflow which runs another.
+type WorkflowDependency struct {
+	From string `json:"from"`
+	// To is the ID of the workflow run, or the target as written when it can't be resolved.
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+	Task string `json:"task"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// WorkflowGraph holds the workflows of projects and the dependencies between them.
+type WorkflowGraph struct {
+	Workflows    []WorkflowNode       `json:"workflows"`
+	Dependencies []WorkflowDependency `json:"dependencies"`
+	// Unresolved are the dependencies on workflows which don't exist or are given by an expression.
+	Unresolved []WorkflowDependency `json:"unresolved"`
+	// Errors are the workflow files which can't be loaded, with the project name prefixed to the file.
+	Errors []dig.Diagnostic `json:"errors"`
+
+	nodes map[string]*WorkflowNode
+}
+
+// BuildWorkflowGraph loads the workflows at the root of each project and links them by their require>
+// and call> tasks. require> targets another project with the project_name or project_id param. call> of
+// a workflow at the project root is a dependency on it; other called files are part of the caller.
+func BuildWorkflowGraph(projects []ProjectFiles) *WorkflowGraph {
+	g := &WorkflowGraph{nodes: map[string]*WorkflowNode{}}
+	projectNames := map[string]string{}
+	loaded := map[string]map[string]*dig.Workflow{}
+	for _, p := range projects {
+		projectNames[p.Project.ID] = p.Project.Name
+		loaded[p.Project.Name] = map[string]*dig.Workflow{}
+		files, err := fs.Glob(p.FS, "*.dig")
+		if err != nil {
+			g.addError(p.Project.Name, dig.Diagnostic{File: ".", Message: err.Error()})
+			continue
+		}
+		for _, file := range files {
+			wf, err := dig.Load(p.FS, file)
+			if err != nil {
+				g.addError(p.Project.Name, loadDiagnostic(file, err))
+				continue
+			}
+			loaded[p.Project.Name][file] = wf
+			node := WorkflowNode{ID: p.Project.Name + "/" + wf.Name, Project: p.Project.Name, Workflow: wf.Name, Scheduled: wf.Schedule != nil}
+			g.Workflows = append(g.Workflows, node)
+		}
+	}
+	sort.Slice(g.Workflows, func(i, j int) bool { return g.Workflows[i].ID < g.Workflows[j].ID })
+	for i := range g.Workflows {
+		g.nodes[g.Workflows[i].ID] = &g.Workflows[i]
+	}
+
+	for _, p := range projects {
+		files := make([]string, 0, len(loaded[p.Project.Name]))
+		for file := range loaded[p.Project.Name] {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			wf := loaded[p.Project.Name][file]
+			s := &graphScan{graph: g, fsys: p.FS, project: p.Project.Name, projectNames: projectNames,
+				from: p.Project.Name + "/" + wf.Name, visited: map[string]bool{file: true}}
+			s.workflow(wf)
+		}
+	}
+	return g
+}
+
+func (g *WorkflowGraph) addError(project string, d dig.Diagnostic) {
+	d.File = project + "/" + d.File
+	g.Errors = append(g.Errors, d)
+}
+
+func loadDiagnostic(file string, err error) dig.Diagnostic {
+	var digErr *dig.Error
+	if errors.As(err, &digErr) {
+		return dig.Diagnostic{File: digErr.File, Line: digErr.Line, Message: digErr.Msg}
+	}
+	return dig.Diagnostic{File: file, Message: err.Error()}
+}
+
+// graphScan collects the dependencies of one workflow, following call> into files outside the root.
+type graphScan struct {
+	graph        *WorkflowGraph
+	fsys         fs.FS
+	project      string
+	projectNames map[string]string
+	from         string
+	visited      map[string]bool
+}
+
+func (s *graphScan) workflow(wf *dig.Workflow) {
+	dir := path.Dir(wf.File)
+	wf.Root.Walk(func(t *dig.Task) error {
+		s.task(dir, t)
+		return nil
+	})
+}
+
+func (s *graphScan) task(dir string, t *dig.Task) {
+	if t.Operator != "require" && t.Operator != "call" {
+		return
+	}
+	dep := WorkflowDependency{From: s.from, Kind: t.Operator, Task: t.FullName, File: s.project + "/" + t.File, Line: t.KeyLine(t.Operator + ">")}
+	command, ok := t.Command.(string)
+	if !ok {
+		dep.To = fmt.Sprint(t.Command)
+		s.graph.Unresolved = append(s.graph.Unresolved, dep)
+		return
+	}
+	dep.To = command
+	if strings.Contains(command, "${") {
+		s.graph.Unresolved = append(s.graph.Unresolved, dep)
+		return
+	}
+
+	if t.Operator == "require" {
+		project := s.project
+		if name, ok := t.Params["project_name"]; ok {
+			project = fmt.Sprint(name)
+		} else if id, ok := t.Params["project_id"]; ok {
+			project = s.projectNames[fmt.Sprint(id)]
+		}
+		s.add(dep, project+"/"+strings.TrimSuffix(command, ".dig"))
+		return
+	}
+
+	target := path.Join(dir, command)
+	if !strings.HasSuffix(target, ".dig") {
+		target += ".dig"
+	}
+	if path.Dir(target) == "." {
+		s.add(dep, s.project+"/"+strings.TrimSuffix(target, ".dig"))
+		return
+	}
+	if s.visited[target] {
+		return
+	}
+	s.visited[target] = true
+	wf, err := dig.Load(s.fsys, target)
+	if err != nil {
+		s.graph.Unresolved = append(s.graph.Unresolved, dep)
+		return
+	}
+	s.workflow(wf)
+}
+
+func (s *graphScan) add(dep WorkflowDependency, to string) {
+	if s.graph.nodes[to] == nil {
+		s.graph.Unresolved = append(s.graph.Unresolved, dep)
+		return
+	}
+	dep.To = to
+	s.graph.Dependencies = append(s.graph.Dependencies, dep)
+}
+
+// GetWorkflowGraph downloads the current revision of every project and builds their workflow graph.
+func (c *Client) GetWorkflowGraph(ctx context.Context) (*WorkflowGraph, error) {
+	projects, err := c.GetProjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]ProjectFiles, 0, len(projects.Projects))
+	for _, project := range projects.Projects {
+		archive, err := c.GetProjectArchive(ctx, project.ID, project.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", project.Name, err)
+		}
+		files = append(files, ProjectFiles{Project: project, FS: archive.FS()})
+	}
+	return BuildWorkflowGraph(files), nil
+}
+
+// edges returns the workflows each workflow depends on, without duplicates and in order.
+func (g *WorkflowGraph) edges() map[string][]string {
+	edges := map[string][]string{}
+	seen := map[[2]string]bool{}
+	for _, dep := range g.Dependencies {
+		if !seen[[2]string{dep.From, dep.To}] {
+			seen[[2]string{dep.From, dep.To}] = true
+			edges[dep.From] = append(edges[dep.From], dep.To)
+		}
+	}
+	for from := range edges {
+		sort.Strings(edges[from])
+	}
+	return edges
+}
+
+// Cycles returns the groups of workflows which depend on each other, including workflows which depend
+// on themselves. Workflows within a group and the groups are in order.
+func (g *WorkflowGraph) Cycles() [][]string {
+	edges := g.edges()
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var cycles [][]string
+	var connect func(id string)
+	connect = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, to := range edges[id] {
+			if _, ok := index[to]; !ok {
+				connect(to)
+				if low[to] < low[id] {
+					low[id] = low[to]
+				}
+			} else if onStack[to] && index[to] < low[id] {
+				low[id] = index[to]
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var group []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			group = append(group, top)
+			if top == id {
+				break
+			}
+		}
+		if len(group) > 1 || containsString(edges[id], id) {
+			sort.Strings(group)
+			cycles = append(cycles, group)
+		}
+	}
+	for _, node := range g.Workflows {
+		if _, ok := index[node.ID]; !ok {
+			connect(node.ID)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Orphans returns the workflows which are neither scheduled nor run by another workflow, in order.
+func (g *WorkflowGraph) Orphans() []string {
+	required := map[string]bool{}
+	for _, dep := range g.Dependencies {
+		if dep.From != dep.To {
+			required[dep.To] = true
+		}
+	}
+	var orphans []string
+	for _, node := range g.Workflows {
+		if !node.Scheduled && !required[node.ID] {
+			orphans = append(orphans, node.ID)
+		}
+	}
+	return orphans
+}
+
+// DependentsOf returns the workflows which run the workflow with the ID directly or through other
+// workflows, in order. These are the workflows a change to it can break.
+func (g *WorkflowGraph) DependentsOf(id string) []string {
+	reverse := map[string][]string{}
+	for from, tos := range g.edges() {
+		for _, to := range tos {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	var dependents []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, from := range reverse[current] {
+			if !seen[from] {
+				seen[from] = true
+				dependents = append(dependents, from)
+				queue = append(queue, from)
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// WriteJSON writes the graph as JSON.
+func (g *WorkflowGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz. Scheduled workflows are drawn bold and
+// call> dependencies dashed.
+func (g *WorkflowGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph workflows {\n  node [shape=box];\n")
+	for _, node := range g.Workflows {
+		style := ""
+		if node.Scheduled {
+			style = " [style=bold]"
+		}
+		fmt.Fprintf(&b, "  %q%s;\n", node.ID, style)
+	}
+	for _, dep := range g.uniqueDependencies() {
+		style := ""
+		if dep.Kind == "call" {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "  %q -> %q%s;\n", dep.From, dep.To, style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Scheduled workflows are drawn as stadiums and
+// call> dependencies dotted.
+func (g *WorkflowGraph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	ids := map[string]string{}
+	for i, node := range g.Workflows {
+		ids[node.ID] = fmt.Sprintf("w%d", i)
+		open, close := "[", "]"
+		if node.Scheduled {
+			open, close = "([", "])"
+		}
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[node.ID], open, strings.ReplaceAll(node.ID, `"`, "#quot;"), close)
+	}
+	for _, dep := range g.uniqueDependencies() {
+		arrow := "-->"
+		if dep.Kind == "call" {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[dep.From], arrow, ids[dep.To])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// uniqueDependencies returns one dependency per pair of workflows and kind, in order.
+func (g *WorkflowGraph) uniqueDependencies() []WorkflowDependency {
+	seen := map[[3]string]bool{}
+	var deps []WorkflowDependency
+	for _, dep := range g.Dependencies {
+		key := [3]string{dep.From, dep.To, dep.Kind}
+		if !seen[key] {
+			seen[key] = true
+			deps = append(deps, dep)
+		}
+	}
+	sort.SliceStable(deps, func(i, j int) bool {
+		if deps[i].From != deps[j].From {
+			return deps[i].From < deps[j].From
+		}
+		return deps[i].To < deps[j].To
+	})
+	return deps
+}
@@ -0,0 +1,138 @@
+package digdaggo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClient_GetWorkflowGraph(t *testing.T) {
+	etl := buildArchive(t, map[string]string{
+		"daily.dig":        "schedule:\n  daily>: 07:00:00\n+load:\n  call>: load\n+steps:\n  call>: lib/steps.dig\n",
+		"load.dig":         "+transform:\n  require>: transform\n  project_name: analytics\n",
+		"lib/steps.dig":    "+report:\n  require>: report\n  project_id: 2\n+again:\n  call>: steps\n",
+		"adhoc.dig":        "+run:\n  require>: ${target}\n+missing:\n  require>: nothing\n",
+		"broken.dig":       "+a: b\n",
+		"queries/load.sql": "select 1",
+	})
+	analytics := buildArchive(t, map[string]string{
+		"transform.dig": "+back:\n  require>: load\n  project_name: etl\n",
+		"report.dig":    "+self:\n  require>: report\n",
+	})
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects": respondJSON(t, Projects{Projects: []Project{{ID: "1", Name: "etl", Revision: "r1"}, {ID: "2", Name: "analytics", Revision: "r2"}}}),
+		"GET /projects/1/archive": func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("revision") != "r1" {
+				t.Errorf("revision wrong. got=%s", req.URL.Query().Get("revision"))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write(etl)
+		},
+		"GET /projects/2/archive": func(w http.ResponseWriter, req *http.Request) {
+			w.Write(analytics)
+		},
+	})
+	defer teardown()
+
+	g, err := client.GetWorkflowGraph(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, node := range g.Workflows {
+		ids = append(ids, node.ID)
+	}
+	if want := []string{"analytics/report", "analytics/transform", "etl/adhoc", "etl/daily", "etl/load"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("workflows wrong. got=%v", ids)
+	}
+	var deps []string
+	for _, dep := range g.Dependencies {
+		deps = append(deps, dep.From+" "+dep.Kind+" "+dep.To)
+	}
+	want := []string{
+		"etl/daily call etl/load",
+		"etl/daily require analytics/report",
+		"etl/load require analytics/transform",
+		"analytics/report require analytics/report",
+		"analytics/transform require etl/load",
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("dependencies wrong. got=%v", deps)
+	}
+	if g.Dependencies[1].File != "etl/lib/steps.dig" || g.Dependencies[1].Line != 2 || g.Dependencies[1].Task != "+steps+report" {
+		t.Fatalf("dependency source wrong. got=%+v", g.Dependencies[1])
+	}
+	if len(g.Unresolved) != 2 || g.Unresolved[0].To != "${target}" || g.Unresolved[1].To != "nothing" {
+		t.Fatalf("unresolved wrong. got=%+v", g.Unresolved)
+	}
+	if len(g.Errors) != 1 || g.Errors[0].File != "etl/broken.dig" {
+		t.Fatalf("errors wrong. got=%+v", g.Errors)
+	}
+
+	if cycles := g.Cycles(); !reflect.DeepEqual(cycles, [][]string{{"analytics/report"}, {"analytics/transform", "etl/load"}}) {
+		t.Fatalf("cycles wrong. got=%v", cycles)
+	}
+	if orphans := g.Orphans(); !reflect.DeepEqual(orphans, []string{"etl/adhoc"}) {
+		t.Fatalf("orphans wrong. got=%v", orphans)
+	}
+	if dependents := g.DependentsOf("analytics/transform"); !reflect.DeepEqual(dependents, []string{"etl/daily", "etl/load"}) {
+		t.Fatalf("dependents wrong. got=%v", dependents)
+	}
+	if dependents := g.DependentsOf("etl/daily"); len(dependents) != 0 {
+		t.Fatalf("dependents wrong. got=%v", dependents)
+	}
+}
+
+func TestWorkflowGraph_Write(t *testing.T) {
+	g := &WorkflowGraph{
+		Workflows: []WorkflowNode{
+			{ID: "etl/daily", Project: "etl", Workflow: "daily", Scheduled: true},
+			{ID: "etl/load", Project: "etl", Workflow: "load"},
+		},
+		Dependencies: []WorkflowDependency{
+			{From: "etl/daily", To: "etl/load", Kind: "call", Task: "+a"},
+			{From: "etl/daily", To: "etl/load", Kind: "call", Task: "+b"},
+		},
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	wantDOT := `digraph workflows {
+  node [shape=box];
+  "etl/daily" [style=bold];
+  "etl/load";
+  "etl/daily" -> "etl/load" [style=dashed];
+}
+`
+	if dot.String() != wantDOT {
+		t.Fatalf("DOT wrong. got=\n%s", dot.String())
+	}
+
+	var mermaid bytes.Buffer
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	wantMermaid := "flowchart LR\n  w0([\"etl/daily\"])\n  w1[\"etl/load\"]\n  w0 -.-> w1\n"
+	if mermaid.String() != wantMermaid {
+		t.Fatalf("Mermaid wrong. got=\n%s", mermaid.String())
+	}
+
+	var out bytes.Buffer
+	if err := g.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded WorkflowGraph
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Dependencies) != 2 || !strings.Contains(out.String(), `"scheduled": true`) {
+		t.Fatalf("JSON wrong. got=%s", out.String())
+	}
+}
@@ -3,7 +3,9 @@ package digdaggo
 import (
 	"context"
 	"log"
+	"net/http"
 	"testing"
+	"time"
 )
 
 func TestClient_GetWorkflows(t *testing.T) {
@@ -101,3 +103,119 @@ func TestClient_GetWorkflowsWithId(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_ResolveWorkflow(t *testing.T) {
+	projectCalls, workflowCalls := 0, 0
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /projects": func(w http.ResponseWriter, req *http.Request) {
+			projectCalls++
+			if req.URL.Query().Get("name") != "test" {
+				t.Errorf("project name wrong. want=test, got=%s", req.URL.Query().Get("name"))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, Projects{Projects: []Project{{ID: "1", Name: "test"}}})(w, req)
+		},
+		"GET /projects/1/workflows/daily": func(w http.ResponseWriter, req *http.Request) {
+			workflowCalls++
+			revision := req.URL.Query().Get("revision")
+			id := "100"
+			if revision == "r1" {
+				id = "50"
+			}
+			respondJSON(t, DetailedWorkflow{ID: id, Name: "daily", Revision: revision, Project: ProjectInWorkflow{ID: "1", Name: "test"}})(w, req)
+		},
+	})
+	defer teardown()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		wf, err := client.ResolveWorkflow(ctx, "test/daily")
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := wf.NumericID()
+		if err != nil || id != 100 {
+			t.Fatalf("workflow id wrong. want=100, got=%d (%v)", id, err)
+		}
+	}
+	if projectCalls != 1 || workflowCalls != 1 {
+		t.Fatalf("lookups were not cached: %d project calls, %d workflow calls", projectCalls, workflowCalls)
+	}
+
+	pinned, err := client.ResolveWorkflowAtRevision(ctx, "test/daily", "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned.ID != "50" || pinned.Revision != "r1" {
+		t.Fatalf("pinned workflow wrong. got=%+v", pinned)
+	}
+
+	client.workflows.forgetProject("test")
+	if _, err := client.ResolveWorkflow(ctx, "test/daily"); err != nil {
+		t.Fatal(err)
+	}
+	if workflowCalls != 3 {
+		t.Fatalf("latest revision was not looked up again after forgetProject: %d workflow calls", workflowCalls)
+	}
+
+	if _, err := client.ResolveWorkflow(ctx, "daily"); err == nil {
+		t.Fatal("expected an error for a reference without project")
+	}
+}
+
+func TestClient_GetTruncatedSessionTime(t *testing.T) {
+	truncated, _ := time.Parse(time.RFC3339, "2022-04-01T00:00:00+09:00")
+	instant, _ := time.Parse(time.RFC3339, "2022-04-01T13:45:10+09:00")
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /workflows/100/truncated_session_time": func(w http.ResponseWriter, req *http.Request) {
+			q := req.URL.Query()
+			if q.Get("mode") != "day" || q.Get("session_time") != "2022-04-01T04:45:10Z" {
+				t.Errorf("query wrong. got=%s", req.URL.RawQuery)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			respondJSON(t, WorkflowSessionTime{SessionTime: truncated, TimeZone: "Asia/Tokyo"})(w, req)
+		},
+	})
+	defer teardown()
+
+	st, err := client.GetTruncatedSessionTime(context.Background(), "100", instant, SessionTimeDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.SessionTime.Equal(truncated) || st.TimeZone != "Asia/Tokyo" {
+		t.Fatalf("session time wrong. got=%+v", st)
+	}
+
+	if _, err := client.GetTruncatedSessionTime(context.Background(), "100", instant, "weekly"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestDetailedWorkflow_Definition(t *testing.T) {
+	client, teardown := setupRoutes(t, map[string]http.HandlerFunc{
+		"GET /workflows/100": func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"id":"100","name":"daily","timezone":"UTC","config":{"+z":{"echo>":"z"},"+a":{"td>":"a.sql","database":"db"}}}`))
+		},
+	})
+	defer teardown()
+
+	wf, err := client.GetWorkflowWithID(context.Background(), "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config, ok := wf.Config.(map[string]interface{}); !ok || len(config) != 2 {
+		t.Fatalf("config wrong. got=%v", wf.Config)
+	}
+	def, err := wf.Definition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Name != "daily" || def.Timezone != "UTC" || len(def.Root.Tasks) != 2 {
+		t.Fatalf("definition wrong. got=%+v", def)
+	}
+	if def.Root.Tasks[0].FullName != "+daily+z" || def.Root.Tasks[1].Operator != "td" || def.Root.Tasks[1].Params["database"] != "db" {
+		t.Fatalf("tasks wrong. got=%+v, %+v", def.Root.Tasks[0], def.Root.Tasks[1])
+	}
+}